@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"time"
+	"unicode/utf8"
+)
+
+// Longest chat message, in characters. Anything longer is cut off.
+const maxChatLength = 280
+
+// Chat rate limit: a burst of chatBurst messages, then one more every chatRefill.
+const (
+	chatBurst  = 5
+	chatRefill = time.Second
+)
+
+var ErrEmptyMessage = errors.New("message is empty")
+
+// SanitizeChat cleans up the text of a chat message, cutting it down to maxChatLength.
+//
+// Returns ErrEmptyMessage if there's nothing left to send.
+func SanitizeChat(text string) (string, error) {
+	text = cleanText(text)
+	if text == "" {
+		return "", ErrEmptyMessage
+	}
+	if utf8.RuneCountInString(text) > maxChatLength {
+		text = string([]rune(text)[:maxChatLength])
+	}
+	return text, nil
+}
+
+// TokenBucket is a simple rate limiter.
+//
+// Each event takes a token, and tokens refill at a steady rate up to a maximum.
+// Not threadsafe, but each one only belongs to a single connection's read loop.
+type TokenBucket struct {
+	tokens   float64
+	max      float64
+	interval time.Duration
+	last     time.Time
+}
+
+// NewTokenBucket creates a full bucket of max tokens, which refills one token every interval.
+func NewTokenBucket(max int, interval time.Duration) *TokenBucket {
+	return &TokenBucket{
+		tokens:   float64(max),
+		max:      float64(max),
+		interval: interval,
+		last:     time.Now(),
+	}
+}
+
+// Allow takes a token if there's one available, and reports whether it did.
+func (b *TokenBucket) Allow() bool {
+	now := time.Now()
+	b.tokens += float64(now.Sub(b.last)) / float64(b.interval)
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSanitizeChat(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr error
+	}{
+		{"plain", "hello", "hello", nil},
+		{"whitespace", "  hello \n\t there ", "hello there", nil},
+		{"empty", "", "", ErrEmptyMessage},
+		{"blank", " \n\t ", "", ErrEmptyMessage},
+		{"invisible", "\u200b\u0007", "", ErrEmptyMessage},
+		{"longest", strings.Repeat("a", maxChatLength), strings.Repeat("a", maxChatLength), nil},
+		{"too long", strings.Repeat("a", maxChatLength+10), strings.Repeat("a", maxChatLength), nil},
+		// Cut by characters, not bytes, so nothing is split mid-character
+		{"too long multibyte", strings.Repeat("é", maxChatLength+1), strings.Repeat("é", maxChatLength), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SanitizeChat(tt.text)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("SanitizeChat(%q) = %q, %v, want %q, %v", tt.text, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenBucketAllow(t *testing.T) {
+	// One step of a test: wait, then try to take a token
+	type step struct {
+		wait time.Duration
+		want bool
+	}
+	tests := []struct {
+		name  string
+		max   int
+		steps []step
+	}{
+		{"burst", 3, []step{{0, true}, {0, true}, {0, true}, {0, false}}},
+		{"refill", 1, []step{{0, true}, {0, false}, {time.Minute, true}, {0, false}}},
+		{"partial refill", 1, []step{{0, true}, {30 * time.Second, false}, {30 * time.Second, true}}},
+		{"refill caps at max", 2, []step{{0, true}, {0, true}, {time.Hour, true}, {0, true}, {0, false}}},
+		{"empty bucket", 0, []step{{0, false}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewTokenBucket(tt.max, time.Minute)
+			for i, s := range tt.steps {
+				// Pretend the wait already happened, rather than sleeping
+				b.last = b.last.Add(-s.wait)
+				if got := b.Allow(); got != s.want {
+					t.Errorf("step %d: Allow() = %t, want %t", i, got, s.want)
+				}
+			}
+		})
+	}
+}
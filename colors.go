@@ -0,0 +1,34 @@
+package main
+
+import "errors"
+
+var ErrInvalidColor = errors.New("not a color in the palette")
+var ErrColorTaken = errors.New("color is already taken")
+
+// Palette lists the colors players can draw with, in the order they're handed out.
+//
+// This needs at least as many colors as the largest room has seats; see maxRoomSize.
+var Palette = []string{
+	"#ff3232",
+	"#ff9232",
+	"#e7ff32",
+	"#32ff87",
+	"#32ffee",
+	"#3295ff",
+	"#c132ff",
+	"#ff326f",
+	"#a0522d",
+	"#9e9e9e",
+	"#32a852",
+	"#ff32d6",
+}
+
+// InPalette checks if color is one of the colors in Palette.
+func InPalette(color string) bool {
+	for _, c := range Palette {
+		if c == color {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Limits for room configuration.
+// Player counts match the box: 3 to 10 players.
+const (
+	minRoomSize     = minPlayers
+	maxRoomSize     = 10
+	defaultRoomSize = 8
+
+	minRounds     = 1
+	maxRounds     = 5
+	defaultRounds = 2
+
+	minTurnTime     = 10 * time.Second
+	maxTurnTime     = 5 * time.Minute
+	defaultTurnTime = time.Minute
+)
+
+// RoomConfig holds the settings chosen when a room is created.
+type RoomConfig struct {
+	// Maximum number of players in room
+	Size int
+	// Number of turns each player takes per game
+	Rounds int
+	// Time limit for each turn; zero means no limit
+	TurnTime time.Duration
+	// Public rooms are listed on the home page; private rooms are only reachable by URL
+	Public bool
+}
+
+// DefaultRoomConfig is used for rooms that weren't explicitly created,
+// e.g. when a user just visits a new room URL.
+func DefaultRoomConfig() RoomConfig {
+	return RoomConfig{
+		Size:     defaultRoomSize,
+		Rounds:   defaultRounds,
+		TurnTime: defaultTurnTime,
+		Public:   false,
+	}
+}
+
+func (c RoomConfig) Validate() error {
+	if c.Size < minRoomSize || c.Size > maxRoomSize {
+		return fmt.Errorf("room size must be between %d and %d", minRoomSize, maxRoomSize)
+	}
+	if c.Rounds < minRounds || c.Rounds > maxRounds {
+		return fmt.Errorf("rounds must be between %d and %d", minRounds, maxRounds)
+	}
+	if c.TurnTime != 0 && (c.TurnTime < minTurnTime || c.TurnTime > maxTurnTime) {
+		return fmt.Errorf("turn time must be between %.0f and %.0f seconds, or 0 for no limit",
+			minTurnTime.Seconds(), maxTurnTime.Seconds())
+	}
+	return nil
+}
+
+// roomConfigRequest is the body of a request to create a room.
+// Omitted fields keep their defaults.
+type roomConfigRequest struct {
+	Size   *int `json:"size"`
+	Rounds *int `json:"rounds"`
+	// Seconds per turn
+	TurnTime *int  `json:"turnTime"`
+	Public   *bool `json:"public"`
+}
+
+// ParseRoomConfig reads a RoomConfig from either a JSON body or form values.
+// Missing fields are filled in from DefaultRoomConfig, then the result is validated.
+func ParseRoomConfig(r *http.Request) (RoomConfig, error) {
+	var req roomConfigRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return RoomConfig{}, fmt.Errorf("invalid JSON: %w", err)
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return RoomConfig{}, fmt.Errorf("invalid form: %w", err)
+		}
+		var err error
+		if req.Size, err = formInt(r, "size"); err != nil {
+			return RoomConfig{}, err
+		}
+		if req.Rounds, err = formInt(r, "rounds"); err != nil {
+			return RoomConfig{}, err
+		}
+		if req.TurnTime, err = formInt(r, "turnTime"); err != nil {
+			return RoomConfig{}, err
+		}
+		if v := r.PostFormValue("public"); v != "" {
+			// Checkboxes submit "on"
+			public := v == "on"
+			if !public {
+				if public, err = strconv.ParseBool(v); err != nil {
+					return RoomConfig{}, fmt.Errorf("invalid value for public: %s", v)
+				}
+			}
+			req.Public = &public
+		}
+	}
+
+	config := DefaultRoomConfig()
+	if req.Size != nil {
+		config.Size = *req.Size
+	}
+	if req.Rounds != nil {
+		config.Rounds = *req.Rounds
+	}
+	if req.TurnTime != nil {
+		// Check the raw seconds first, since huge values overflow time.Duration and could wrap around to 0
+		seconds := *req.TurnTime
+		if seconds != 0 && (seconds < int(minTurnTime.Seconds()) || seconds > int(maxTurnTime.Seconds())) {
+			return RoomConfig{}, fmt.Errorf("turn time must be between %.0f and %.0f seconds, or 0 for no limit",
+				minTurnTime.Seconds(), maxTurnTime.Seconds())
+		}
+		config.TurnTime = time.Duration(seconds) * time.Second
+	}
+	if req.Public != nil {
+		config.Public = *req.Public
+	}
+	return config, config.Validate()
+}
+
+// formInt parses an optional integer form value, returning nil if it's absent.
+func formInt(r *http.Request, key string) (*int, error) {
+	v := r.PostFormValue(key)
+	if v == "" {
+		return nil, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %s", key, v)
+	}
+	return &i, nil
+}
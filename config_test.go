@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRoomConfig(t *testing.T) {
+	defaults := DefaultRoomConfig()
+	tests := []struct {
+		name    string
+		body    string
+		want    RoomConfig
+		wantErr bool
+	}{
+		{"defaults", `{}`, defaults, false},
+		{"all fields", `{"size": 5, "rounds": 3, "turnTime": 30, "public": true}`,
+			RoomConfig{Size: 5, Rounds: 3, TurnTime: 30 * time.Second, Public: true}, false},
+		{"smallest", `{"size": 3, "rounds": 1, "turnTime": 10}`,
+			RoomConfig{Size: 3, Rounds: 1, TurnTime: 10 * time.Second}, false},
+		{"largest", `{"size": 10, "rounds": 5, "turnTime": 300}`,
+			RoomConfig{Size: 10, Rounds: 5, TurnTime: 5 * time.Minute}, false},
+		{"untimed", `{"turnTime": 0}`,
+			RoomConfig{Size: defaults.Size, Rounds: defaults.Rounds, TurnTime: 0}, false},
+		{"too small", `{"size": 2}`, RoomConfig{}, true},
+		{"too big", `{"size": 11}`, RoomConfig{}, true},
+		{"too few rounds", `{"rounds": 0}`, RoomConfig{}, true},
+		{"too many rounds", `{"rounds": 6}`, RoomConfig{}, true},
+		{"turn too short", `{"turnTime": 9}`, RoomConfig{}, true},
+		{"turn too long", `{"turnTime": 301}`, RoomConfig{}, true},
+		{"negative turn", `{"turnTime": -30}`, RoomConfig{}, true},
+		// Multiplied out to a time.Duration, this wraps around to about 10 seconds
+		{"turn overflows into range", `{"turnTime": 18446744084}`, RoomConfig{}, true},
+		{"wrong type", `{"size": "big"}`, RoomConfig{}, true},
+		{"invalid JSON", `{`, RoomConfig{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/rooms", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			got, err := ParseRoomConfig(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseRoomConfig() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseRoomConfig() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRoomConfigForm(t *testing.T) {
+	tests := []struct {
+		name    string
+		form    url.Values
+		want    RoomConfig
+		wantErr bool
+	}{
+		{"checkbox", url.Values{"size": {"4"}, "turnTime": {"60"}, "public": {"on"}},
+			RoomConfig{Size: 4, Rounds: defaultRounds, TurnTime: time.Minute, Public: true}, false},
+		{"bool", url.Values{"rounds": {"1"}, "public": {"false"}},
+			RoomConfig{Size: defaultRoomSize, Rounds: 1, TurnTime: defaultTurnTime}, false},
+		{"not a number", url.Values{"size": {"lots"}}, RoomConfig{}, true},
+		{"not a bool", url.Values{"public": {"maybe"}}, RoomConfig{}, true},
+		{"out of range", url.Values{"turnTime": {"18446744084"}}, RoomConfig{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/rooms", strings.NewReader(tt.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			got, err := ParseRoomConfig(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseRoomConfig() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseRoomConfig() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
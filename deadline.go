@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Deadlines for the phases of the game other than drawing.
+// Drawing uses the room's configured turn time.
+const (
+	promptTime = time.Minute
+	votingTime = 90 * time.Second
+	guessTime  = time.Minute
+)
+
+// Prompts picked on the Muse's behalf if they run out of time.
+var fallbackPrompts = []string{
+	"cat", "house", "bicycle", "octopus", "castle", "guitar", "volcano", "robot",
+	"umbrella", "dragon", "lighthouse", "snowman", "pirate ship", "cactus", "rocket",
+}
+
+// resetDeadlineUnsafe starts the countdown for the game's current state, replacing any previous countdown.
+// During Drawing, this is the countdown for the current turn.
+//
+// If the room has no turn time configured, turns have no time limit,
+// but the other phases still do, so a missing player can't stall the game.
+// Not threadsafe.
+func (r *Room) resetDeadlineUnsafe() {
+	r.stopDeadlineUnsafe()
+
+	var limit time.Duration
+	var onExpire func()
+	switch r.Game.State {
+	case GettingPrompt:
+		limit = promptTime
+		onExpire = r.expirePromptUnsafe
+	case Drawing:
+		if r.Config.TurnTime == 0 {
+			return
+		}
+		limit = r.Config.TurnTime
+		player := r.Game.Drawing
+		onExpire = func() {
+			r.notifyAllUnsafe(fmt.Sprintf("Player #%d ran out of time!", player+1), false)
+			r.endTurnUnsafe(player)
+		}
+	case Voting:
+		limit = votingTime
+		onExpire = r.expireVotingUnsafe
+	case PoserGuessing, ValidatingGuess:
+		limit = guessTime
+		onExpire = r.expireGuessUnsafe
+	default:
+		// No deadline while Waiting
+		return
+	}
+
+	id := r.deadlineID
+	r.deadlineStarted = time.Now()
+	r.deadlineLimit = limit
+	r.deadline = time.AfterFunc(limit, func() {
+		r.mux.Lock()
+		defer r.mux.Unlock()
+		if id != r.deadlineID {
+			// This countdown was stopped or replaced after the timer fired
+			return
+		}
+		r.stopDeadlineUnsafe()
+		onExpire()
+	})
+}
+
+// stopDeadlineUnsafe cancels the current countdown, if any.
+// Not threadsafe.
+func (r *Room) stopDeadlineUnsafe() {
+	if r.deadline != nil {
+		r.deadline.Stop()
+		r.deadline = nil
+	}
+	// Stop() can't cancel a timer that has already fired and is waiting on the lock,
+	// so invalidate it as well.
+	r.deadlineID++
+	r.deadlineStarted = time.Time{}
+	r.deadlineLimit = 0
+}
+
+// countdownUnsafe returns when the current countdown started and its length in milliseconds,
+// as used by StateMessage and TurnMessage.
+// Not threadsafe.
+func (r *Room) countdownUnsafe() (time.Time, int64) {
+	return r.deadlineStarted, r.deadlineLimit.Milliseconds()
+}
+
+// expirePromptUnsafe picks a prompt for a Muse who ran out of time.
+// Not threadsafe.
+func (r *Room) expirePromptUnsafe() {
+	r.notifyAllUnsafe("The Muse ran out of time, so a prompt was picked for them.", false)
+	if err := r.setPromptUnsafe(fallbackPrompts[rand.Intn(len(fallbackPrompts))]); err != nil {
+		log.Printf("error setting fallback prompt: %s", err)
+		r.abortGameUnsafe(fmt.Sprintf("Couldn't set prompt: %s", err))
+	}
+}
+
+// expireVotingUnsafe tallies whatever votes were cast before voting ran out of time.
+// Not threadsafe.
+func (r *Room) expireVotingUnsafe() {
+	if err := r.Game.CloseVoting(); err != nil {
+		log.Printf("error closing voting: %s", err)
+		r.abortGameUnsafe(fmt.Sprintf("Couldn't close voting: %s", err))
+		return
+	}
+	r.notifyAllUnsafe("Voting ran out of time!", false)
+	r.afterTallyUnsafe()
+}
+
+// expireGuessUnsafe counts the Poser's guess as wrong when the Poser or Muse runs out of time.
+// Not threadsafe.
+func (r *Room) expireGuessUnsafe() {
+	if err := r.Game.RejectGuess(); err != nil {
+		log.Printf("error rejecting guess: %s", err)
+		r.abortGameUnsafe(fmt.Sprintf("Couldn't end game: %s", err))
+		return
+	}
+	r.notifyAllUnsafe("Time's up! The Poser's guess doesn't count.", false)
+	r.resetDeadlineUnsafe()
+	r.broadcastStateUnsafe()
+	r.finishGameUnsafe()
+}
@@ -0,0 +1,82 @@
+package main
+
+import "errors"
+
+// ErrorCode identifies the kind of error in an ErrorMessage, so clients can react to it programmatically.
+//
+// These are part of the protocol: don't change existing codes, only add new ones.
+type ErrorCode string
+
+const (
+	CodeInternal         ErrorCode = "internal"
+	CodeUnknownType      ErrorCode = "unknown_type"
+	CodeInvalidPayload   ErrorCode = "invalid_payload"
+	CodeRoomFull         ErrorCode = "room_full"
+	CodeGameInProgress   ErrorCode = "game_in_progress"
+	CodeNotEnoughPlayers ErrorCode = "not_enough_players"
+	CodeInvalidState     ErrorCode = "invalid_state"
+	CodeNotYourTurn      ErrorCode = "not_your_turn"
+	CodeNotHost          ErrorCode = "not_host"
+	CodeNotMuse          ErrorCode = "not_muse"
+	CodeNotPoser         ErrorCode = "not_poser"
+	CodeSpectating       ErrorCode = "spectating"
+	CodeMuted            ErrorCode = "muted"
+	CodeRateLimited      ErrorCode = "rate_limited"
+	CodeLeaksPrompt      ErrorCode = "leaks_prompt"
+	CodeInvalidName      ErrorCode = "invalid_name"
+	CodeNameTaken        ErrorCode = "name_taken"
+	CodeInvalidColor     ErrorCode = "invalid_color"
+	CodeColorTaken       ErrorCode = "color_taken"
+	CodeInvalidMatch     ErrorCode = "invalid_match"
+	CodeNoSuchPlayer     ErrorCode = "no_such_player"
+	CodeSelfTarget       ErrorCode = "self_target"
+	CodeEmptyMessage     ErrorCode = "empty_message"
+	CodeSelfVote         ErrorCode = "self_vote"
+	CodeAlreadyVoted     ErrorCode = "already_voted"
+	CodeInvalidStroke    ErrorCode = "invalid_stroke"
+	CodeBlankPrompt      ErrorCode = "blank_prompt"
+	CodeBlankGuess       ErrorCode = "blank_guess"
+)
+
+// errorCodes maps errors to their codes. The first match wins, so more specific errors go first.
+var errorCodes = []struct {
+	err  error
+	code ErrorCode
+}{
+	{ErrInvalidPayload, CodeInvalidPayload},
+	{ErrRoomFull, CodeRoomFull},
+	{ErrGameInProgress, CodeGameInProgress},
+	{ErrNotEnoughPlayers, CodeNotEnoughPlayers},
+	{ErrNotYourTurn, CodeNotYourTurn},
+	{ErrInvalidState, CodeInvalidState},
+	{ErrNotHost, CodeNotHost},
+	{ErrNotMuse, CodeNotMuse},
+	{ErrNotPoser, CodeNotPoser},
+	{ErrSpectating, CodeSpectating},
+	{ErrMuted, CodeMuted},
+	{ErrRateLimited, CodeRateLimited},
+	{ErrLeaksPrompt, CodeLeaksPrompt},
+	{ErrInvalidName, CodeInvalidName},
+	{ErrNameTaken, CodeNameTaken},
+	{ErrInvalidColor, CodeInvalidColor},
+	{ErrColorTaken, CodeColorTaken},
+	{ErrInvalidMatch, CodeInvalidMatch},
+	{ErrNoSuchPlayer, CodeNoSuchPlayer},
+	{ErrSelfTarget, CodeSelfTarget},
+	{ErrEmptyMessage, CodeEmptyMessage},
+	{ErrSelfVote, CodeSelfVote},
+	{ErrAlreadyVoted, CodeAlreadyVoted},
+	{ErrInvalidStroke, CodeInvalidStroke},
+	{ErrBlankPrompt, CodeBlankPrompt},
+	{ErrBlankGuess, CodeBlankGuess},
+}
+
+// CodeOf finds the code for err, falling back to CodeInternal for errors clients can't do anything about.
+func CodeOf(err error) ErrorCode {
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return CodeInternal
+}
@@ -1,16 +1,40 @@
 import { useRef, useState, useEffect } from 'react'
-import { Canvas, DrawCallback, DrawCallbackContext } from './components/Canvas'
+import { Canvas, DrawCallback, DrawCallbackContext, DrawData } from './components/Canvas'
 import { Chat, Message } from './components/Chat'
-import { User, UserList} from './components/UserList'
+import { User, UserList, nameKey } from './components/UserList'
 import { Notification, Notifications } from './components/Notifications'
 import { StartForm } from './components/StartForm'
 import { PromptForm } from './components/PromptForm'
+import { Tally, VoteForm } from './components/VoteForm'
+import { GuessForm, JudgeForm } from './components/GuessForm'
+import { GameOutcome, Outcome } from './components/Outcome'
+import { MatchForm, MatchInfo } from './components/MatchForm'
+import { Countdown, Deadline } from './components/Countdown'
 import { State, Role } from './enums';
+import { PROTOCOL_VERSION, ErrorMessage } from './protocol';
 import WebSocketContext from './WebSocketContext'
 import './App.css'
 
+// Resume token for this room, so reloading the page reclaims our seat
+const tokenKey = `poser-token:${location.pathname}`;
+const token = sessionStorage.getItem(tokenKey);
+
+// Display name, from the page's query string or whatever we picked last time
+const name = new URLSearchParams(location.search).get('name') ?? localStorage.getItem(nameKey);
+
 const wsProtocol = location.protocol === 'https:' ? 'wss:' : 'ws:';
-const wsUrl = `${wsProtocol}//${location.host}${location.pathname.replace('/room/', '/ws/')}`;
+
+const wsParams = new URLSearchParams();
+// Version of the websocket protocol we speak; the server turns us away if it doesn't match
+wsParams.set('protocol', PROTOCOL_VERSION.toString());
+if (token !== null) {
+  wsParams.set('token', token);
+}
+if (name !== null) {
+  wsParams.set('name', name);
+}
+const wsQuery = `?${wsParams}`;
+const wsUrl = `${wsProtocol}//${location.host}${location.pathname.replace('/room/', '/ws/')}${wsQuery}`;
 
 const conn = new WebSocket(wsUrl, 'json');
 
@@ -20,11 +44,27 @@ function App() {
   let [messages, setMessages] = useState<Message[]>([]);
   let [notifications, setNotifications] = useState<Notification[]>([]);
   let [playerNumber, setPlayerNumber] = useState<number>(0);
+  let [owner, setOwner] = useState<number>(0);
+  let [spectators, setSpectators] = useState<number>(0);
+  let [palette, setPalette] = useState<string[]>([]);
+  // Set when the server says there aren't enough players to start, until someone else joins
+  let [notEnoughPlayers, setNotEnoughPlayers] = useState<boolean>(false);
+  let [queuePosition, setQueuePosition] = useState<number>(0);
   let [gameState, setGameState] = useState<State>(State.Waiting);
   let [playerRole, setPlayerRole] = useState<Role>(Role.Artist);
   let [currentPlayer, setCurrentPlayer] = useState<number>(0);
+  let [tally, setTally] = useState<Tally | null>(null);
+  let [guess, setGuess] = useState<string>('');
+  let [outcome, setOutcome] = useState<GameOutcome | null>(null);
+  let [scores, setScores] = useState<Map<number, number>>(new Map());
+  let [match, setMatch] = useState<MatchInfo>(new MatchInfo(0, 0, 0));
+  let [deadline, setDeadline] = useState<Deadline | null>(null);
 
   let connRef = useRef<WebSocket | null>(conn);
+  // Sequence number of the last room event we've seen, to spot any we've missed
+  let seqRef = useRef<number>(0);
+  // Our player number, for use in the message handler below
+  let playerNumberRef = useRef<number>(0);
   let drawRef = useRef<DrawCallback>(new DrawCallback((_) => {
     console.error("draw callback called before initialization");
   }));
@@ -43,44 +83,121 @@ function App() {
     conn.onerror = (e) => {
       console.error(`wsConnection error `, e);
     };
+    conn.onclose = (e) => {
+      // Let the user know if the server told us why, e.g. being kicked
+      if (e.reason !== "") {
+        setNotifications((ns) => [...ns, new Notification(Date.now(), e.reason, true)]);
+      }
+    };
     conn.onmessage = (e) => {
       let data = JSON.parse(e.data);
       const d = data.data; // may be undefined
+      // Room events are numbered by the server. Catch-up messages repeat the latest number.
+      if (data.seq !== undefined) {
+        if (seqRef.current > 0 && data.seq > seqRef.current + 1) {
+          console.warn(`missed ${data.seq - seqRef.current - 1} events before #${data.seq}`);
+        }
+        seqRef.current = Math.max(seqRef.current, data.seq);
+      }
       switch (data.type) {
         case 'connection':
           setUserId(d.id);
           setPlayerNumber(d.playerNumber);
+          playerNumberRef.current = d.playerNumber;
+          setPalette(d.palette);
+          // Spectators have no seat to resume
+          if (!d.spectator) {
+            sessionStorage.setItem(tokenKey, d.token);
+            setQueuePosition(0);
+          }
           break;
         case 'players':
           console.log(`Ids: ${d.ids}`);
+          setNotEnoughPlayers(false);
           // Note: playerNumber (idx) is 1-indexed, not 0
-          let users = d.ids.map((id: string, idx: number) => new User(id, idx+1, "", false))
+          let users = d.ids.map((id: string, idx: number) => new User(id, idx+1, d.names[idx], idx+1 === d.owner, d.colors[idx]))
                               .filter((u: User) => u.id !== ""); // ignore empty slots
+          // Colors come from the server, so point the player-N classes at them
+          d.colors.forEach((color: string, idx: number) => {
+            document.documentElement.style.setProperty(`--player-${idx+1}`, color);
+          });
           setUserList(users);
+          setOwner(d.owner);
+          setSpectators(d.spectators);
+          break;
+        case 'queue':
+          setQueuePosition(d.position);
           break;
         case 'chat':
-          let m = new Message(d.id, d.playerNumber, d.user, d.timestamp, d.text);
+          let m = new Message(d.id, d.playerNumber, d.user, d.timestamp, d.text, d.name, data.seq);
           console.log(`Chat: ${m.toWSMessage()}`);
           setMessages((messages) => [...messages, m]);
           break;
         case 'draw':
-          drawRef.current.callback(data.data);
+          // We already drew our own strokes as we made them
+          if (d.playerNumber !== playerNumberRef.current) {
+            drawRef.current.callback(d);
+          }
+          break;
+        case 'strokes':
+          // Replay the whole canvas, e.g. after joining late
+          drawRef.current.clear();
+          d.strokes.forEach((stroke: DrawData) => drawRef.current.callback(stroke));
+          break;
+        case 'clear':
+          drawRef.current.clear();
           break;
         case 'notification':
           let n = new Notification(d.timestamp, d.message, d.isError)
           // We can do .sort((a,b) => a.timestamp-b.timestamp) if we want timestamp ordering, but for now order of arrival seems best.
           setNotifications((ns) => [...ns, n]);
           break;
+        case 'error':
+          // The server rejected something we sent
+          let err: ErrorMessage = d;
+          console.warn(`Error ${err.code}${data.requestId ? ` for request ${data.requestId}` : ''}: ${err.message}`);
+          if (err.code === 'not_enough_players') {
+            setNotEnoughPlayers(true);
+          }
+          setNotifications((ns) => [...ns, new Notification(Date.now(), err.message, true)]);
+          break;
         case 'role':
           setPlayerRole(d.role);
           break;
         case 'state':
           console.log(`State: ${d.state}`);
           setGameState(d.state);
+          setDeadline(new Deadline(d.started, d.duration));
+          if (d.state === State.GettingPrompt) {
+            // New game, so clear results of the last one
+            setTally(null);
+            setGuess('');
+            setOutcome(null);
+          }
+          break;
+        case 'tally':
+          setTally(new Tally(d.accused, d.votes, d.tied));
+          break;
+        case 'guess':
+          setGuess(d.guess);
+          break;
+        case 'scores':
+          setScores(new Map(d.scores.map((s: { playerNumber: number, score: number }) => [s.playerNumber, s.score])));
+          break;
+        case 'match':
+          setMatch(new MatchInfo(d.targetScore, d.targetGames, d.gamesPlayed));
+          break;
+        case 'match_over':
+          // Winner is announced via notification, and the reset scores follow separately.
+          console.log(`Match over, winners: ${d.winners}`);
+          break;
+        case 'outcome':
+          setOutcome(new GameOutcome(d.prompt, d.muse, d.poser, d.caught, d.guess, d.guessCorrect, d.winner));
           break;
         case 'turn':
           console.log(`Current player: ${d.playerNumber}`);
           setCurrentPlayer(d.playerNumber);
+          setDeadline(new Deadline(d.started, d.duration));
           break;
         case undefined:
           console.log("Undefined message type");
@@ -103,14 +220,23 @@ function App() {
   return (
     <>
       <h1>Poser</h1>
+      {playerNumber === 0 && <p>You're spectating.{queuePosition > 0 && ` You're #${queuePosition} in line for a seat.`}</p>}
       <WebSocketContext.Provider value={connRef.current}>
       <DrawCallbackContext.Provider value={drawRef.current}>
         <Notifications notifications={notifications}/>
-        <StartForm gameState={gameState} playerNumber={playerNumber} />
+        <Countdown deadline={deadline} />
+        <StartForm gameState={gameState} isHost={owner === playerNumber} notEnoughPlayers={notEnoughPlayers} />
+        <MatchForm gameState={gameState} isHost={owner === playerNumber} match={match} />
         <PromptForm gameState={gameState} playerRole={playerRole} />
-        <UserList users={userList} />
-        <Chat messages={messages} />
-        <Canvas gameState={gameState} playerNumber={playerNumber} currentPlayer={currentPlayer}/>
+        <VoteForm gameState={gameState} playerNumber={playerNumber} users={userList} tally={tally} />
+        <GuessForm gameState={gameState} playerRole={playerRole} />
+        <JudgeForm gameState={gameState} playerRole={playerRole} guess={guess} />
+        <Outcome outcome={outcome} />
+        <UserList users={userList} scores={scores} isHost={owner === playerNumber} spectators={spectators}
+                  palette={palette} playerNumber={playerNumber} gameState={gameState} />
+        <Chat messages={messages} isHost={owner === playerNumber} />
+        <Canvas gameState={gameState} playerNumber={playerNumber} currentPlayer={currentPlayer}
+                color={userList.find((u) => u.playerNumber === playerNumber)?.color ?? ''} />
       </DrawCallbackContext.Provider>
       </WebSocketContext.Provider>
     </>
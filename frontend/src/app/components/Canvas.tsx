@@ -6,6 +6,7 @@ import { State } from '../enums.tsx'
 class DrawCallback {
     constructor(
         public callback: (d: DrawData) => void = (_: DrawData) => {},
+        public clear: () => void = () => {},
     ) {}
 }
 const DrawCallbackContext = createContext<DrawCallback>(new DrawCallback());
@@ -22,19 +23,6 @@ enum MouseButtons {
 }
 */
 
-// We could try to be clever and use window.getComputedStyle(document.body).getPropertyValue('--player-1')
-// to ensure these don't go out of sync, but for now it's sufficient (and efficient) to just define twice:
-const playerColors: string[] = [
-    '#ff3232',
-    '#ff9232',
-    '#e7ff32',
-    '#32ff87',
-    '#32ffee',
-    '#3295ff',
-    '#c132ff',
-    '#ff326f',
-];
-
 // https://developer.mozilla.org/en-US/docs/Web/API/MouseEvent/button
 enum MouseButton {
     Primary = 0,
@@ -44,6 +32,14 @@ enum MouseButton {
     Forward = 4,
 }
 
+// Where a DrawData segment falls within its stroke.
+// The server uses this to enforce one stroke per turn.
+enum StrokePhase {
+    Start = "start",
+    Segment = "segment",
+    End = "end",
+}
+
 class DrawData {
     constructor(
         public lastX: number,
@@ -51,6 +47,9 @@ class DrawData {
         public x: number,
         public y: number,
         public playerNumber: number,
+        public stroke: StrokePhase = StrokePhase.Start,
+        // Set by the server from the player's chosen color
+        public color: string = '',
     ) {}
 
     // Reset all coordinates to the same point.
@@ -72,6 +71,8 @@ class DrawData {
 }
 
 interface CanvasProps {
+    // Our own drawing color
+    color: string;
     currentPlayer: number;
     gameState: State;
     playerNumber: number;
@@ -85,7 +86,8 @@ function Canvas(props: CanvasProps) {
     }
     const drawCallback = useContext(DrawCallbackContext);
 
-    const freeDraw = (props.gameState === State.Waiting);
+    // Spectators (player number 0) can't draw at all
+    const freeDraw = (props.gameState === State.Waiting) && props.playerNumber > 0;
     const playerTurn = (props.gameState === State.Drawing) && (props.playerNumber === props.currentPlayer);
     let canDraw = freeDraw || playerTurn;
 
@@ -117,27 +119,54 @@ function Canvas(props: CanvasProps) {
         //canvas.width = window.innerWidth;
         //canvas.height = window.innerHeight;
 
-        // Clear canvas when room is created and when each game starts.
-        if (props.gameState === State.Waiting || props.gameState === State.GettingPrompt) {
+        // Clear canvas when each game starts.
+        // Otherwise, the server tells us when to clear, so the canvas stays in sync with its stroke log.
+        if (props.gameState === State.GettingPrompt) {
             ctx.clearRect(0, 0, canvas.width, canvas.height);
         }
 
         let drawing = false;
         let first = true;
-        const drawData = new DrawData(0, 0, 0, 0, props.playerNumber);
+        const drawData = new DrawData(0, 0, 0, 0, props.playerNumber, StrokePhase.Start, props.color);
+
+        function send(stroke: StrokePhase) {
+            drawData.stroke = stroke;
+            if (ws !== null) {
+                ws.send(JSON.stringify({
+                    type: 'draw',
+                    data: drawData,
+                }));
+            } else {
+                console.error("cannot send draw data: no WebSocket")
+            }
+        }
+
+        // Finish the current stroke. During a game, the server ends our turn when it sees this.
+        function endStroke() {
+            if (drawing) {
+                send(StrokePhase.End);
+                if (playerTurn) {
+                    // Prevent further drawing
+                    canDraw = false;
+                }
+            }
+            first = true;
+            drawing = false;
+        }
 
         function draw(d: DrawData) {
             ctx.beginPath();
             ctx.moveTo(d.lastX, d.lastY);
             ctx.lineTo(d.x, d.y);
 
-            ctx.strokeStyle = playerColors[d.playerNumber - 1]; // players 1-indexed, colors 0-indexed
+            ctx.strokeStyle = d.color;
             ctx.lineWidth = 5;
             ctx.lineCap = 'round';
             ctx.stroke();
             ctx.closePath();
         }
         drawCallback.callback = draw;
+        drawCallback.clear = () => ctx.clearRect(0, 0, canvas.width, canvas.height);
 
         function move(e: MouseEvent) {
             if (!canDraw) { return; }
@@ -148,14 +177,7 @@ function Canvas(props: CanvasProps) {
                 }
                 drawData.update(e.offsetX, e.offsetY);
                 draw(drawData);
-                if (ws !== null) {
-                    ws.send(JSON.stringify({
-                        type: 'draw',
-                        data: drawData,
-                    }));
-                } else {
-                    console.error("cannot send draw data: no WebSocket")
-                }
+                send(StrokePhase.Segment);
             }
         }
         canvas.onmousemove = move;
@@ -166,47 +188,32 @@ function Canvas(props: CanvasProps) {
             first = true;
             drawing = true;
             draw(drawData);
+            send(StrokePhase.Start);
         }
         canvas.onmouseup = (e: MouseEvent) => {
             if (e.button != MouseButton.Primary) { return; }
             if (!canDraw) { return; }
-            // End turn if ending a stroke on current turn (NOT during Waiting)
-            if (drawing && playerTurn) {
-                // Note that this condition is important for protecting canDraw
-                // in case drawing=false, canDraw=true, user clicks outside canvas,
-                // then releases mouse inside canvas! This would waste the player's turn.
-
-                // Prevent further drawing
-                canDraw = false;
-                // Notify server we're done.
-                if (ws != null) {
-                    ws.send(JSON.stringify({
-                        type: 'done',
-                        data: null,
-                    }));
-                } else {
-                    console.error("cannot end turn: no WebSocket")
-                }
-            }
-            first = true;
-            drawing = false;
+            // Note that endStroke only ends the turn if we were drawing. This protects canDraw
+            // in case drawing=false, canDraw=true, user clicks outside canvas,
+            // then releases mouse inside canvas! This would waste the player's turn.
+            endStroke();
         }
         canvas.onmouseleave = (_: MouseEvent) => {
-            // If we've left the canvas, stop drawing.
-            first = true;
-            drawing = false;
+            // If we've left the canvas, the stroke is over.
+            endStroke();
         }
 
         return () => { // cleanup
             window.onresize = null;
             drawCallback.callback = (_: DrawData) => { console.error("draw callback called after cleanup"); };
+            drawCallback.clear = () => { console.error("clear callback called after cleanup"); };
         };
 
-    }, [props.playerNumber, props.gameState, props.currentPlayer, playerTurn, canDraw]);
+    }, [props.playerNumber, props.gameState, props.currentPlayer, props.color, playerTurn, canDraw]);
 
     return (
         <canvas ref={canvasRef}></canvas>
     )
 }
 
-export { Canvas, DrawCallback, DrawCallbackContext };
+export { Canvas, DrawCallback, DrawCallbackContext, DrawData };
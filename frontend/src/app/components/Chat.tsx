@@ -3,13 +3,14 @@ import WebSocketContext from '../WebSocketContext';
 
 interface ChatProps {
     messages: Message[];
+    isHost: boolean;
 }
   
 function Chat(props: ChatProps) {
     const messages = props.messages;
-    const chatMessages = messages.sort((a,b) => a.timestamp-b.timestamp).map((m: Message) => {
+    const chatMessages = messages.sort((a,b) => a.seq-b.seq).map((m: Message) => {
         const className = `player-${m.playerNumber}`
-        return (<p key={m.id} className={className}><ChatItem message={m} /></p>);
+        return (<p key={m.id} className={className}><ChatItem message={m} isHost={props.isHost} /></p>);
     }
     );
 
@@ -24,13 +25,37 @@ function Chat(props: ChatProps) {
 
 interface ChatItemProps {
     message: Message;
+    isHost: boolean;
 }
 
 function ChatItem(props: ChatItemProps) {
+    const ws = useContext(WebSocketContext);
     const m = props.message;
+    // Spectators aren't in the user list, so the host moderates them from their messages
+    const isSpectator = m.playerNumber === 0;
+
+    // Send a host-only command targeting the sender, by ID since spectators have no player number
+    const hostCommand = (type: string, muted: boolean = false) => {
+        if (ws !== null) {
+            ws.send(JSON.stringify({
+                type: type,
+                data: { id: m.user, muted: muted },
+            }));
+        } else {
+            console.error(`cannot send ${type}: no WebSocket`)
+        }
+    };
+
     return (
         <div>
-            <p>Player #{m.playerNumber}: {m.text}</p>
+            <p>{m.name || (isSpectator ? 'Spectator' : `Player #${m.playerNumber}`)}: {m.text}</p>
+            {props.isHost && isSpectator &&
+                <>
+                    <button onClick={() => hostCommand("mute", true)}>Mute</button>
+                    <button onClick={() => hostCommand("mute", false)}>Unmute</button>
+                    <button onClick={() => hostCommand("kick")}>Kick</button>
+                    <button onClick={() => hostCommand("ban")}>Ban</button>
+                </>}
         </div>
     );
 }
@@ -42,6 +67,10 @@ class Message {
         public user: string,
         public timestamp: number,
         public text: string,
+        // Display name of the sender, if they've picked one
+        public name: string = '',
+        // Order of the message within the room, set by the server
+        public seq: number = 0,
     ) {}
 
     string() {
@@ -62,6 +91,10 @@ function ChatInput() {
 
     const handleSubmit = (e: FormEvent<HTMLFormElement>) => {
         e.preventDefault();
+        if (message.trim() === '') {
+            // The server drops empty messages anyway
+            return;
+        }
         if (ws !== null) {
             // Most of these are overwritten on the server side except timestamp and message
             let m = new Message('', 0, '', Date.now(), message)
@@ -85,6 +118,7 @@ function ChatInput() {
                 type="text"
                 name="chat-message"
                 value={message}
+                maxLength={280}
                 placeholder="Type a message and hit Enter"
                 />
             <button>Send</button>
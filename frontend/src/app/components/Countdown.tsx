@@ -0,0 +1,45 @@
+import { useEffect, useState } from 'react';
+
+class Deadline {
+    constructor(
+        // ISO timestamp of when the countdown started
+        public started: string,
+        // Length of the countdown in milliseconds; zero means no limit
+        public duration: number,
+    ) {}
+
+    remaining(): number {
+        const end = Date.parse(this.started) + this.duration;
+        return Math.max(0, end - Date.now());
+    }
+}
+
+interface CountdownProps {
+    deadline: Deadline | null,
+}
+
+function Countdown(props: CountdownProps) {
+    let [remaining, setRemaining] = useState<number>(0);
+
+    useEffect(() => {
+        const d = props.deadline;
+        if (d === null || d.duration === 0) {
+            setRemaining(0);
+            return;
+        }
+        setRemaining(d.remaining());
+        const interval = setInterval(() => setRemaining(d.remaining()), 250);
+        return () => clearInterval(interval);
+    }, [props.deadline]);
+
+    if (props.deadline === null || props.deadline.duration === 0) {
+        return null;
+    }
+    return (
+        <div id="countdown-component">
+            {Math.ceil(remaining / 1000)}s left
+        </div>
+    );
+}
+
+export { Countdown, Deadline }
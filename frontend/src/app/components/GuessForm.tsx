@@ -0,0 +1,70 @@
+import { FormEvent, useContext } from 'react';
+import WebSocketContext from '../WebSocketContext';
+import { Role, State } from '../enums';
+
+interface GuessFormProps {
+    gameState: State,
+    playerRole: Role,
+}
+
+// GuessForm lets a caught Poser take one guess at the prompt.
+function GuessForm(props: GuessFormProps) {
+    const ws = useContext(WebSocketContext);
+    const formActive = props.gameState === State.PoserGuessing && props.playerRole === Role.Poser;
+    const className = formActive ? "" : "inactive";
+
+    const handleSubmit = (e: FormEvent<HTMLFormElement>) => {
+        e.preventDefault();
+        if (ws !== null) {
+            ws.send(JSON.stringify({
+                type: "guess",
+                data: { guess: e.currentTarget.guess.value },
+            }));
+        } else {
+            console.error("cannot send guess: no WebSocket")
+        }
+    };
+    return (
+      <div id="guess-form-component" className={className}>
+        <form id="guess-form" onSubmit={handleSubmit}>
+            <fieldset disabled={!formActive}>
+                <input type="text" name="guess" placeholder="What were they drawing?"></input>
+                <input type="submit" value="Guess"></input>
+            </fieldset>
+        </form>
+      </div>
+    );
+}
+
+interface JudgeFormProps {
+    gameState: State,
+    playerRole: Role,
+    guess: string,
+}
+
+// JudgeForm lets the Muse decide whether the Poser's guess matches the prompt.
+function JudgeForm(props: JudgeFormProps) {
+    const ws = useContext(WebSocketContext);
+    const formActive = props.gameState === State.ValidatingGuess && props.playerRole === Role.Muse;
+    const className = formActive ? "" : "inactive";
+
+    const judge = (correct: boolean) => {
+        if (ws !== null) {
+            ws.send(JSON.stringify({
+                type: "judge",
+                data: { correct: correct },
+            }));
+        } else {
+            console.error("cannot send judgement: no WebSocket")
+        }
+    };
+    return (
+      <div id="judge-form-component" className={className}>
+        <p>The Poser guessed "{props.guess}". Is that correct?</p>
+        <button disabled={!formActive} onClick={() => judge(true)}>Correct</button>
+        <button disabled={!formActive} onClick={() => judge(false)}>Wrong</button>
+      </div>
+    );
+}
+
+export { GuessForm, JudgeForm }
@@ -0,0 +1,62 @@
+import { FormEvent, useContext } from 'react';
+import WebSocketContext from '../WebSocketContext';
+import { State } from '../enums';
+
+class MatchInfo {
+    constructor(
+        public targetScore: number,
+        public targetGames: number,
+        public gamesPlayed: number,
+    ) {}
+}
+
+interface MatchFormProps {
+    gameState: State,
+    isHost: boolean,
+    match: MatchInfo,
+}
+
+function MatchForm(props: MatchFormProps) {
+    const ws = useContext(WebSocketContext);
+    const m = props.match;
+    const formActive = props.gameState === State.Waiting && props.isHost;
+    const className = formActive ? "" : "inactive";
+
+    const handleSubmit = (e: FormEvent<HTMLFormElement>) => {
+        e.preventDefault();
+        if (ws !== null) {
+            ws.send(JSON.stringify({
+                type: "match",
+                data: {
+                    targetScore: parseInt(e.currentTarget.targetScore.value) || 0,
+                    targetGames: parseInt(e.currentTarget.targetGames.value) || 0,
+                },
+            }));
+        } else {
+            console.error("cannot send match: no WebSocket")
+        }
+    };
+
+    let progress = `Game ${m.gamesPlayed + 1}`;
+    if (m.targetGames > 0) {
+        progress += ` of ${m.targetGames}`;
+    }
+    if (m.targetScore > 0) {
+        progress += `, first to ${m.targetScore} points`;
+    }
+
+    return (
+      <div id="match-form-component">
+        <p>{progress}</p>
+        <form id="match-form" className={className} onSubmit={handleSubmit}>
+            <fieldset disabled={!formActive}>
+                <input type="number" name="targetScore" min="0" placeholder="Target score" defaultValue={m.targetScore || ""}></input>
+                <input type="number" name="targetGames" min="0" placeholder="Number of games" defaultValue={m.targetGames || ""}></input>
+                <input type="submit" value="Set match"></input>
+            </fieldset>
+        </form>
+      </div>
+    );
+}
+
+export { MatchForm, MatchInfo }
@@ -0,0 +1,37 @@
+import { Role } from '../enums';
+
+class GameOutcome {
+    constructor(
+        public prompt: string,
+        public muse: number,
+        public poser: number,
+        public caught: boolean,
+        public guess: string,
+        public guessCorrect: boolean,
+        public winner: Role,
+    ) {}
+}
+
+interface OutcomeProps {
+    outcome: GameOutcome | null,
+}
+
+function Outcome(props: OutcomeProps) {
+    const o = props.outcome;
+    if (o === null) {
+        return null;
+    }
+    const winner = o.winner === Role.Poser ? "The Poser and the Muse win!" : "The Artists win!";
+    return (
+        <div id="outcome-component">
+            <h2>{winner}</h2>
+            <p>The prompt was "{o.prompt}".</p>
+            <p>
+                <span className={`player-${o.poser}`}>Player #{o.poser}</span> was the Poser
+                {o.caught ? ` and guessed "${o.guess}".` : " and escaped!"}
+            </p>
+        </div>
+    );
+}
+
+export { GameOutcome, Outcome }
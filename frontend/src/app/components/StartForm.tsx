@@ -4,12 +4,14 @@ import { State } from '../enums';
 
 interface StartFormProps {
     gameState: State,
-    playerNumber: number,
+    isHost: boolean,
+    // The server said there aren't enough players to start
+    notEnoughPlayers: boolean,
 }
 
 function StartForm(props: StartFormProps) {
     const ws = useContext(WebSocketContext);
-    const formActive = props.gameState === State.Waiting && props.playerNumber === 1;
+    const formActive = props.gameState === State.Waiting && props.isHost;
     const className = formActive ? "" : "inactive";
 
     const handleSubmit = (e: FormEvent<HTMLFormElement>) => {
@@ -17,18 +19,29 @@ function StartForm(props: StartFormProps) {
         if (ws !== null) {
 
             ws.send(JSON.stringify({
-                type: "start"
+                type: "start",
+                // Lets us match up any error with this request
+                requestId: `start-${Date.now()}`,
             }));
-            //TODO disable form?
         } else {
             console.error("cannot send start: no WebSocket")
         }
     };
+    const handleClear = () => {
+        if (ws !== null) {
+            ws.send(JSON.stringify({
+                type: "clear"
+            }));
+        } else {
+            console.error("cannot send clear: no WebSocket")
+        }
+    };
     return (
       <div id="start-form-component" className={className}>
         <form id="start-form" onSubmit={handleSubmit}>
             <fieldset disabled={!formActive}>
-                <input type="submit" value="Start"></input>
+                <input type="submit" value="Start" disabled={props.notEnoughPlayers}></input>
+                <input type="button" value="Clear canvas" onClick={handleClear}></input>
             </fieldset>
         </form>
       </div>
@@ -1,5 +1,23 @@
+import { ChangeEvent, FormEvent, useContext, useState } from 'react';
+import WebSocketContext from '../WebSocketContext';
+import { State } from '../enums';
+
+// Where we remember our display name, so it's picked again when joining the next room
+const nameKey = 'poser-name';
+
 interface UserListProps {
   users: User[];
+  // Map of player number -> score
+  scores: Map<number, number>;
+  // Whether the current user is the host, and so can hand hosting to someone else
+  isHost: boolean;
+  // Number of spectators watching the room
+  spectators: number;
+  // Colors players can pick from
+  palette: string[];
+  // Our own player number, or 0 if spectating
+  playerNumber: number;
+  gameState: State;
 }
 
 class User {
@@ -8,22 +26,125 @@ class User {
     public playerNumber: number,
     public name: string,
     public owner: boolean,
+    public color: string = '',
   ) {}
 }
 
 function UserList(props: UserListProps) {
+  const ws = useContext(WebSocketContext);
   const users = props.users;
+
+  // Send a host-only command targeting another player
+  const hostCommand = (type: string, playerNumber: number, muted: boolean = false) => {
+    if (ws !== null) {
+      ws.send(JSON.stringify({
+        type: type,
+        data: { playerNumber: playerNumber, muted: muted },
+      }));
+    } else {
+      console.error(`cannot send ${type}: no WebSocket`)
+    }
+  };
+
   const listItems = users.map((user: User) => {
     const className = `player-${user.playerNumber}`
-    const userName = `Player #${user.playerNumber}`;
-    return (<li key={user.playerNumber} className={className}>{userName}</li>);
+    const userName = user.name || `Player #${user.playerNumber}`;
+    const score = props.scores.get(user.playerNumber) ?? 0;
+    return (
+      <li key={user.playerNumber} className={className}>
+        {userName} ({score}){user.owner && " (host)"}
+        {props.isHost && !user.owner &&
+          <>
+            <button onClick={() => hostCommand("transfer_host", user.playerNumber)}>Make host</button>
+            <button onClick={() => hostCommand("mute", user.playerNumber, true)}>Mute</button>
+            <button onClick={() => hostCommand("mute", user.playerNumber, false)}>Unmute</button>
+            <button onClick={() => hostCommand("kick", user.playerNumber)}>Kick</button>
+            <button onClick={() => hostCommand("ban", user.playerNumber)}>Ban</button>
+          </>}
+      </li>
+    );
   });
   return (
     <div id="userlist-widget">
       <h2>Users</h2>
       <ul>{listItems}</ul>
+      {props.spectators > 0 && <p>{props.spectators} watching</p>}
+      <NameForm />
+      {props.playerNumber > 0 && props.gameState === State.Waiting &&
+        <ColorPicker palette={props.palette} users={users} playerNumber={props.playerNumber} />}
     </div>
   );
 }
 
-export { User, UserList }
+function NameForm() {
+  const ws = useContext(WebSocketContext);
+  let [name, setName] = useState(localStorage.getItem(nameKey) ?? '');
+
+  const handleSubmit = (e: FormEvent<HTMLFormElement>) => {
+    e.preventDefault();
+    if (ws !== null) {
+      // The server checks the name, and lets us know if it's rejected
+      ws.send(JSON.stringify({
+        type: 'set_name',
+        data: { name: name },
+      }));
+      localStorage.setItem(nameKey, name);
+    } else {
+      console.error("cannot set name: no WebSocket")
+    }
+  };
+
+  const handleChange = (e: ChangeEvent<HTMLInputElement>) => {
+    setName(e.target.value);
+  };
+
+  return (
+    <form onSubmit={handleSubmit}>
+      <input
+        onChange={handleChange}
+        type="text"
+        name="name"
+        value={name}
+        maxLength={24}
+        placeholder="Your name"
+        />
+      <button>Set name</button>
+    </form>
+  );
+}
+
+interface ColorPickerProps {
+  palette: string[];
+  users: User[];
+  playerNumber: number;
+}
+
+function ColorPicker(props: ColorPickerProps) {
+  const ws = useContext(WebSocketContext);
+
+  const pick = (color: string) => {
+    if (ws !== null) {
+      ws.send(JSON.stringify({
+        type: 'set_color',
+        data: { color: color },
+      }));
+    } else {
+      console.error("cannot set color: no WebSocket")
+    }
+  };
+
+  // Colors other players are using can't be picked
+  const taken = new Set(props.users.filter((u) => u.playerNumber !== props.playerNumber).map((u) => u.color));
+  const buttons = props.palette.map((color: string) =>
+    <button
+      key={color}
+      disabled={taken.has(color)}
+      onClick={() => pick(color)}
+      style={{ backgroundColor: color }}
+      aria-label={`Pick color ${color}`}
+      >&nbsp;</button>
+  );
+  return <div>Color: {buttons}</div>;
+}
+
+export { User, UserList, nameKey }
@@ -0,0 +1,86 @@
+import { useContext, useEffect, useState } from 'react';
+import WebSocketContext from '../WebSocketContext';
+import { State } from '../enums';
+import { User } from './UserList';
+
+class Tally {
+    constructor(
+        public accused: number,
+        public votes: Record<number, number>,
+        public tied: number[],
+    ) {}
+}
+
+interface VoteFormProps {
+    gameState: State,
+    playerNumber: number,
+    users: User[],
+    tally: Tally | null,
+}
+
+function VoteForm(props: VoteFormProps) {
+    const ws = useContext(WebSocketContext);
+    let [voted, setVoted] = useState<boolean>(false);
+
+    // Allow voting again in the next game
+    useEffect(() => {
+        if (props.gameState !== State.Voting) {
+            setVoted(false);
+        }
+    }, [props.gameState]);
+
+    const formActive = props.gameState === State.Voting && !voted;
+    const className = formActive ? "" : "inactive";
+
+    const vote = (playerNumber: number) => {
+        if (ws !== null) {
+            ws.send(JSON.stringify({
+                type: "vote",
+                data: { playerNumber: playerNumber },
+            }));
+            setVoted(true);
+        } else {
+            console.error("cannot send vote: no WebSocket")
+        }
+    };
+
+    const buttons = props.users
+        .filter((u: User) => u.playerNumber !== props.playerNumber)
+        .map((u: User) =>
+            <button key={u.playerNumber} className={`player-${u.playerNumber}`} onClick={() => vote(u.playerNumber)}>
+                Player #{u.playerNumber}
+            </button>
+        );
+
+    return (
+      <div id="vote-form-component">
+        <div className={className}>
+            <h2>Who is the Poser?</h2>
+            {buttons}
+        </div>
+        {props.tally !== null && <TallyResult tally={props.tally} />}
+      </div>
+    );
+}
+
+interface TallyResultProps {
+    tally: Tally,
+}
+
+function TallyResult(props: TallyResultProps) {
+    const t = props.tally;
+    const counts = Object.entries(t.votes).map(([playerNumber, count]) =>
+        <li key={playerNumber} className={`player-${playerNumber}`}>Player #{playerNumber}: {count}</li>
+    );
+    const summary = t.accused === 0
+        ? `Tied between ${t.tied.map((p) => `Player #${p}`).join(', ')}`
+        : `Player #${t.accused} was accused`;
+    return (
+        <div id="tally-result">
+            <p>{summary}</p>
+            <ul>{counts}</ul>
+        </div>
+    );
+}
+
+export { Tally, VoteForm }
@@ -4,6 +4,7 @@ enum State {
   Drawing = "Drawing",
   Voting = "Voting",
   PoserGuessing = "PoserGuessing",
+  ValidatingGuess = "ValidatingGuess",
 }
 
 enum Role {
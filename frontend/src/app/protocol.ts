@@ -0,0 +1,242 @@
+// Code generated by `go run . -types`; DO NOT EDIT.
+// Types of the websocket protocol's messages, generated from the Go message structs.
+
+export const PROTOCOL_VERSION = 1;
+export const MIN_PROTOCOL_VERSION = 1;
+
+export type ErrorCode =
+  | 'internal'
+  | 'unknown_type'
+  | 'invalid_payload'
+  | 'room_full'
+  | 'game_in_progress'
+  | 'not_enough_players'
+  | 'not_your_turn'
+  | 'invalid_state'
+  | 'not_host'
+  | 'not_muse'
+  | 'not_poser'
+  | 'spectating'
+  | 'muted'
+  | 'rate_limited'
+  | 'leaks_prompt'
+  | 'invalid_name'
+  | 'name_taken'
+  | 'invalid_color'
+  | 'color_taken'
+  | 'invalid_match'
+  | 'no_such_player'
+  | 'self_target'
+  | 'empty_message'
+  | 'self_vote'
+  | 'already_voted'
+  | 'invalid_stroke'
+  | 'blank_prompt'
+  | 'blank_guess';
+
+export type Role =
+  | 'Artist'
+  | 'Muse'
+  | 'Poser';
+
+export type State =
+  | 'Waiting'
+  | 'GettingPrompt'
+  | 'Drawing'
+  | 'Voting'
+  | 'PoserGuessing'
+  | 'ValidatingGuess';
+
+export type StrokePhase =
+  | 'start'
+  | 'segment'
+  | 'end';
+
+export interface ChatMessage {
+  id: string;
+  playerNumber: number;
+  text: string;
+  timestamp: number;
+  user: string;
+  name: string;
+}
+
+export interface ColorMessage {
+  color: string;
+}
+
+export interface ConnectionMessage {
+  id: string;
+  playerNumber: number;
+  token: string;
+  spectator: boolean;
+  palette: string[];
+  protocol: number;
+}
+
+export interface DrawMessage {
+  lastX: number;
+  lastY: number;
+  x: number;
+  y: number;
+  playerNumber: number;
+  stroke: StrokePhase;
+  color: string;
+}
+
+export interface ErrorMessage {
+  code: ErrorCode;
+  message: string;
+}
+
+export interface GuessMessage {
+  guess: string;
+}
+
+export interface JudgeMessage {
+  correct: boolean;
+}
+
+export interface MatchMessage {
+  targetScore: number;
+  targetGames: number;
+  gamesPlayed: number;
+}
+
+export interface MatchOverMessage {
+  winners: number[];
+  scores: Score[];
+}
+
+export interface ModerateMessage {
+  playerNumber: number;
+  id?: string;
+  muted: boolean;
+}
+
+export interface NameMessage {
+  name: string;
+}
+
+export interface NotificationMessage {
+  timestamp: string;
+  message: string;
+  isError: boolean;
+}
+
+export interface OutcomeMessage {
+  prompt: string;
+  muse: number;
+  poser: number;
+  caught: boolean;
+  guess: string;
+  guessCorrect: boolean;
+  winner: Role;
+}
+
+export interface PlayersMessage {
+  ids: string[];
+  names: string[];
+  colors: string[];
+  owner: number;
+  spectators: number;
+}
+
+export interface PromptMessage {
+  prompt: string;
+}
+
+export interface QueueMessage {
+  position: number;
+}
+
+export interface RoleMessage {
+  role: Role;
+}
+
+export interface Score {
+  id: string;
+  playerNumber: number;
+  score: number;
+}
+
+export interface ScoresMessage {
+  scores: Score[];
+}
+
+export interface StateMessage {
+  state: State;
+  started: string;
+  duration: number;
+}
+
+export interface StrokesMessage {
+  strokes: DrawMessage[];
+}
+
+export interface TallyMessage {
+  accused: number;
+  votes: Record<string, number>;
+  tied: number[];
+}
+
+export interface TransferHostMessage {
+  playerNumber: number;
+}
+
+export interface TurnMessage {
+  playerNumber: number;
+  started: string;
+  duration: number;
+}
+
+export interface VoteMessage {
+  playerNumber: number;
+}
+
+// Fields common to every message
+interface Envelope {
+  seq?: number;
+  timestamp?: number;
+  requestId?: string;
+}
+
+// A message sent by the client to the server
+export type ClientMessage =
+  | Envelope & { type: 'ban'; data: ModerateMessage }
+  | Envelope & { type: 'chat'; data: ChatMessage }
+  | Envelope & { type: 'clear'; data?: unknown }
+  | Envelope & { type: 'done'; data?: unknown }
+  | Envelope & { type: 'draw'; data: DrawMessage }
+  | Envelope & { type: 'guess'; data: GuessMessage }
+  | Envelope & { type: 'judge'; data: JudgeMessage }
+  | Envelope & { type: 'kick'; data: ModerateMessage }
+  | Envelope & { type: 'match'; data: MatchMessage }
+  | Envelope & { type: 'mute'; data: ModerateMessage }
+  | Envelope & { type: 'prompt'; data: PromptMessage }
+  | Envelope & { type: 'set_color'; data: ColorMessage }
+  | Envelope & { type: 'set_name'; data: NameMessage }
+  | Envelope & { type: 'start'; data?: unknown }
+  | Envelope & { type: 'transfer_host'; data: TransferHostMessage }
+  | Envelope & { type: 'vote'; data: VoteMessage };
+
+// A message sent by the server to the client
+export type ServerMessage =
+  | Envelope & { type: 'chat'; data: ChatMessage }
+  | Envelope & { type: 'clear'; data?: unknown }
+  | Envelope & { type: 'connection'; data: ConnectionMessage }
+  | Envelope & { type: 'draw'; data: DrawMessage }
+  | Envelope & { type: 'error'; data: ErrorMessage }
+  | Envelope & { type: 'guess'; data: GuessMessage }
+  | Envelope & { type: 'match'; data: MatchMessage }
+  | Envelope & { type: 'match_over'; data: MatchOverMessage }
+  | Envelope & { type: 'notification'; data: NotificationMessage }
+  | Envelope & { type: 'outcome'; data: OutcomeMessage }
+  | Envelope & { type: 'players'; data: PlayersMessage }
+  | Envelope & { type: 'queue'; data: QueueMessage }
+  | Envelope & { type: 'role'; data: RoleMessage }
+  | Envelope & { type: 'scores'; data: ScoresMessage }
+  | Envelope & { type: 'state'; data: StateMessage }
+  | Envelope & { type: 'strokes'; data: StrokesMessage }
+  | Envelope & { type: 'tally'; data: TallyMessage }
+  | Envelope & { type: 'turn'; data: TurnMessage };
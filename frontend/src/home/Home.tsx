@@ -1,12 +1,49 @@
+import { useEffect, useState } from 'react'
 import './Home.css'
 
+interface RoomSummary {
+  id: string;
+  players: number;
+  size: number;
+  state: string;
+}
+
 function Home() {
+  let [rooms, setRooms] = useState<RoomSummary[]>([]);
+
+  useEffect(() => {
+    fetch('/rooms')
+      .then((res) => res.json())
+      .then((rs: RoomSummary[]) => setRooms(rs))
+      .catch((e) => console.error("failed to fetch public rooms", e));
+  }, []);
+
+  const roomList = rooms.map((r: RoomSummary) =>
+    <li key={r.id}>
+      <a href={`/room/${r.id}`}>{r.id.slice(0, 8)}</a> ({r.players}/{r.size}, {r.state})
+    </li>
+  );
+
   return (
     <>
       <h1>Poser</h1>
       <form action="/" method="POST">
+        <label>
+          Players <input type="number" name="size" min="3" max="10" defaultValue="8"/>
+        </label>
+        <label>
+          Rounds <input type="number" name="rounds" min="1" max="5" defaultValue="2"/>
+        </label>
+        <label>
+          Seconds per turn (0 for no limit) <input type="number" name="turnTime" min="0" max="300" defaultValue="60"/>
+        </label>
+        <label>
+          Public <input type="checkbox" name="public"/>
+        </label>
         <input type="submit" value="New Room"/>
       </form>
+      <h2>Public rooms</h2>
+      <ul>{roomList}</ul>
     </>
   )
 }
@@ -5,12 +5,28 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"strings"
 )
 
+// Fewest players a game can have, counting the Muse and the Poser
+const minPlayers = 3
+
 var ErrInvalidState = errors.New("invalid game state")
+var ErrAlreadyVoted = errors.New("player has already voted")
+var ErrSelfVote = errors.New("player cannot vote for themselves")
+var ErrNotYourTurn = errors.New("it is not your turn")
+var ErrInvalidStroke = errors.New("invalid stroke")
+var ErrNotEnoughPlayers = errors.New("not enough players to start game")
+var ErrBlankPrompt = errors.New("prompt can't be blank")
+var ErrBlankGuess = errors.New("guess can't be blank")
 
-// This can be made a room config variable later
-const maxRounds = 2
+// Points awarded at the end of a game, following the official rules.
+const (
+	// Awarded to both the Poser and the Muse when the Poser wins
+	poserWinPoints = 2
+	// Awarded to each Artist (but not the Muse) when the Artists win
+	artistWinPoints = 1
+)
 
 // Player states
 type PlayerState struct {
@@ -29,12 +45,46 @@ const (
 	Drawing       State = "Drawing"
 	Voting        State = "Voting"
 	PoserGuessing State = "PoserGuessing"
-	// ValidatingGuess?
+	// Muse is deciding whether the Poser's guess matches the prompt
+	ValidatingGuess State = "ValidatingGuess"
+)
+
+// Departure describes how a game handled a player leaving; see Game.RemovePlayer.
+type Departure int
+
+const (
+	// Player wasn't in a game in progress
+	DepartureIgnored Departure = iota
+	// Player was dropped from the game, which carries on as before
+	DepartureRemoved
+	// The Muse left before picking a prompt, so another player is now the Muse
+	DepartureMuseReassigned
+	// The current artist left, so their turn passed to the next player (or to voting)
+	DepartureTurnSkipped
+	// Everyone left in the game had already voted, so the votes were tallied
+	DepartureVotingClosed
+	// The Muse left before judging the Poser's guess, so the guess was counted as wrong
+	DepartureGuessRejected
+	// The Poser left, so the game was aborted
+	DeparturePoserLeft
+	// Too few players remain to continue, so the game was aborted
+	DepartureNotEnoughPlayers
+)
+
+// Phases of a single stroke, carried by each DrawMessage.
+//
+// A stroke is a start, any number of segments, then an end.
+type StrokePhase string
+
+const (
+	StrokeStart   StrokePhase = "start"
+	StrokeSegment StrokePhase = "segment"
+	StrokeEnd     StrokePhase = "end"
 )
 
 // Player roles
 type Game struct {
-	// Current State of the game (Waiting, GettingPrompt, Drawing, Voting, PoserGuessing)
+	// Current State of the game (Waiting, GettingPrompt, Drawing, Voting, PoserGuessing, ValidatingGuess)
 	State State
 	// Array of player numbers
 	Players []int
@@ -42,14 +92,51 @@ type Game struct {
 	PlayerStates map[int]*PlayerState
 	// Current round
 	Round int
+	// Number of turns each player takes per game
+	Rounds int
 	// Index of Muse
 	Muse int
 	// Index of Poser
 	Poser int
 	// Index of player who is currently drawing
 	Drawing int
+	// Whether the current player is partway through their stroke
+	Stroking bool
 	// Prompt for current game
 	Prompt string
+	// Map of voter -> player they voted for
+	Votes map[int]int
+	// Result of the vote, once every player has voted
+	Tally *Tally
+	// Poser's guess at the prompt, if they were caught
+	Guess string
+	// Result of the game, once it has ended
+	Outcome *Outcome
+}
+
+// Tally is the result of the vote at the end of a game.
+type Tally struct {
+	// Index of the player with the most votes, or -1 if there was a tie
+	Accused int
+	// Map of player -> number of votes received
+	Counts map[int]int
+	// Players tied for the most votes, if any
+	Tied []int
+}
+
+// Outcome describes how a game ended.
+type Outcome struct {
+	// Whether the vote accused the Poser
+	Caught bool
+	// Whether the Poser guessed the prompt after being caught
+	GuessCorrect bool
+}
+
+// PoserWins reports whether the Poser (and Muse) won the game.
+//
+// The Poser wins by escaping the vote, or by guessing the prompt after being caught.
+func (o *Outcome) PoserWins() bool {
+	return !o.Caught || o.GuessCorrect
 }
 
 // Abort game, resetting values to defaults.
@@ -60,7 +147,13 @@ func (g *Game) Abort() {
 	g.Muse = 0
 	g.Poser = 0
 	g.Drawing = 0
+	g.Stroking = false
 	g.Round = 0
+	g.Prompt = ""
+	g.Votes = nil
+	g.Tally = nil
+	g.Guess = ""
+	g.Outcome = nil
 }
 
 // IsJoinable checks if game can currently be joined by a user.
@@ -69,13 +162,37 @@ func (g *Game) IsJoinable() bool {
 }
 
 // Game expects a list of player numbers.
+// The Muse is picked at random.
 func (g *Game) Start(players []int) error {
+	if len(players) == 0 {
+		return ErrNotEnoughPlayers
+	}
+	return g.StartWithMuse(players, players[rand.Intn(len(players))])
+}
+
+// StartWithMuse starts a game with a chosen Muse, who must be one of players.
+func (g *Game) StartWithMuse(players []int, muse int) error {
 	if g.State != Waiting {
 		return ErrGameInProgress
 	}
 
-	if len(players) < 2 {
-		return fmt.Errorf("not enough players to start game")
+	if len(players) < minPlayers {
+		return ErrNotEnoughPlayers
+	}
+
+	if g.Rounds < 1 {
+		return fmt.Errorf("game must have at least one round, got %d", g.Rounds)
+	}
+
+	// Check everything before changing anything, so a failed start leaves the game as it was
+	museIndex := -1
+	for i, p := range players {
+		if p == muse {
+			museIndex = i
+		}
+	}
+	if museIndex == -1 {
+		return fmt.Errorf("muse %d is not one of the players", muse)
 	}
 
 	g.Players = make([]int, len(players))
@@ -93,16 +210,20 @@ func (g *Game) Start(players []int) error {
 	choices := make([]int, len(players))
 	copy(choices, players)
 
-	// Pick Muse
-	m := rand.Intn(len(choices))
-	g.Muse = choices[m]
+	g.Muse = muse
 	// Pick first player (can be Muse!)
 	g.Drawing = choices[rand.Intn(len(choices))]
 	// Drop Muse from choices
-	choices = append(choices[:m], choices[m+1:]...)
+	choices = append(choices[:museIndex], choices[museIndex+1:]...)
 	// Pick Poser (after removing Muse)
 	g.Poser = choices[rand.Intn(len(choices))]
 
+	g.Prompt = ""
+	g.Votes = make(map[int]int)
+	g.Tally = nil
+	g.Guess = ""
+	g.Outcome = nil
+
 	g.State = GettingPrompt
 	return nil
 }
@@ -112,6 +233,11 @@ func (g *Game) SetPrompt(prompt string) error {
 		return ErrInvalidState
 	}
 
+	// A blank prompt would make a blank guess correct
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return ErrBlankPrompt
+	}
 	g.Prompt = prompt
 	g.State = Drawing
 	return nil
@@ -123,11 +249,12 @@ func (g *Game) EndTurn(player int) error {
 	}
 
 	if player != g.Drawing {
-		return fmt.Errorf("player %d is not drawing", player)
+		return ErrNotYourTurn
 	}
 
 	// Increment turn count for player
 	g.PlayerStates[player].TurnsTaken++
+	g.Stroking = false
 
 	// Find next player
 	nextIndex := (g.PlayerStates[player].Index + 1) % len(g.Players)
@@ -136,7 +263,7 @@ func (g *Game) EndTurn(player int) error {
 		log.Println("index of next player not found in PlayerStates")
 		return fmt.Errorf("player #%d is next, but not found in PlayerStates", g.Players[nextIndex])
 	}
-	if nextPlayer.TurnsTaken == maxRounds {
+	if nextPlayer.TurnsTaken == g.Rounds {
 		// We've wrapped back around, everyone has played.
 		g.State = Voting
 		return nil
@@ -145,3 +272,272 @@ func (g *Game) EndTurn(player int) error {
 	g.Drawing = g.Players[nextIndex]
 	return nil
 }
+
+// Draw checks that part of a stroke by player is allowed during Drawing.
+//
+// Each player draws exactly one continuous stroke per turn, so ending the stroke ends the turn.
+// endsTurn reports when that happens, in which case the caller should follow up with EndTurn.
+func (g *Game) Draw(player int, phase StrokePhase) (endsTurn bool, err error) {
+	if g.State != Drawing {
+		return false, ErrInvalidState
+	}
+
+	if player != g.Drawing {
+		return false, ErrNotYourTurn
+	}
+
+	switch phase {
+	case StrokeStart:
+		if g.Stroking {
+			return false, fmt.Errorf("%w: stroke already started", ErrInvalidStroke)
+		}
+		g.Stroking = true
+		return false, nil
+	case StrokeSegment:
+		if !g.Stroking {
+			return false, fmt.Errorf("%w: stroke not started", ErrInvalidStroke)
+		}
+		return false, nil
+	case StrokeEnd:
+		if !g.Stroking {
+			return false, fmt.Errorf("%w: stroke not started", ErrInvalidStroke)
+		}
+		g.Stroking = false
+		return true, nil
+	default:
+		return false, fmt.Errorf("%w: unknown phase %q", ErrInvalidStroke, phase)
+	}
+}
+
+// RemovePlayer drops a player who has left the room from the game.
+//
+// What happens next depends on the player's role and the state of the game;
+// the returned Departure tells the caller which case applied.
+func (g *Game) RemovePlayer(player int) Departure {
+	ps, ok := g.PlayerStates[player]
+	if g.State == Waiting || !ok {
+		return DepartureIgnored
+	}
+
+	// There's no game without the Poser
+	if player == g.Poser {
+		g.Abort()
+		return DeparturePoserLeft
+	}
+	if len(g.Players)-1 < minPlayers {
+		g.Abort()
+		return DepartureNotEnoughPlayers
+	}
+
+	// Find who's after the player before removing them
+	next := g.Players[(ps.Index+1)%len(g.Players)]
+
+	g.Players = append(g.Players[:ps.Index], g.Players[ps.Index+1:]...)
+	delete(g.PlayerStates, player)
+	for i, p := range g.Players {
+		g.PlayerStates[p].Index = i
+	}
+	delete(g.Votes, player)
+
+	switch g.State {
+	case GettingPrompt:
+		if player == g.Drawing {
+			// Nobody has drawn yet, so the next player just goes first
+			g.Drawing = next
+		}
+		if player == g.Muse {
+			// The Poser can't also be the Muse
+			choices := make([]int, 0, len(g.Players))
+			for _, p := range g.Players {
+				if p != g.Poser {
+					choices = append(choices, p)
+				}
+			}
+			g.Muse = choices[rand.Intn(len(choices))]
+			return DepartureMuseReassigned
+		}
+	case Drawing:
+		if player == g.Drawing {
+			g.Stroking = false
+			if g.PlayerStates[next].TurnsTaken == g.Rounds {
+				// Player was the last to draw
+				g.State = Voting
+			} else {
+				g.Drawing = next
+			}
+			return DepartureTurnSkipped
+		}
+	case Voting:
+		if len(g.Votes) == len(g.Players) {
+			g.tally()
+			return DepartureVotingClosed
+		}
+	case ValidatingGuess:
+		if player == g.Muse {
+			g.end(&Outcome{Caught: true, GuessCorrect: false})
+			return DepartureGuessRejected
+		}
+	}
+	return DepartureRemoved
+}
+
+// CastVote records voter's ballot for target.
+// Once every player has voted, the votes are tallied and the game ends.
+func (g *Game) CastVote(voter, target int) error {
+	if g.State != Voting {
+		return ErrInvalidState
+	}
+
+	if _, ok := g.PlayerStates[voter]; !ok {
+		return fmt.Errorf("%w: player #%d is not in the game", ErrNoSuchPlayer, voter+1)
+	}
+	if _, ok := g.PlayerStates[target]; !ok {
+		return fmt.Errorf("%w: player #%d is not in the game", ErrNoSuchPlayer, target+1)
+	}
+	if voter == target {
+		return ErrSelfVote
+	}
+	if _, ok := g.Votes[voter]; ok {
+		return ErrAlreadyVoted
+	}
+
+	g.Votes[voter] = target
+	if len(g.Votes) == len(g.Players) {
+		g.tally()
+	}
+	return nil
+}
+
+// CloseVoting tallies whatever votes have been cast so far,
+// e.g. when voting runs out of time.
+func (g *Game) CloseVoting() error {
+	if g.State != Voting {
+		return ErrInvalidState
+	}
+	g.tally()
+	return nil
+}
+
+// tally counts the votes.
+// If the Poser was accused, they get a chance to guess the prompt.
+// Otherwise, the Poser escaped and the game ends.
+//
+// A tie means nobody is accused.
+func (g *Game) tally() {
+	counts := make(map[int]int)
+	for _, target := range g.Votes {
+		counts[target]++
+	}
+
+	// Walk Players rather than counts so ties are reported in turn order.
+	most := 0
+	leaders := make([]int, 0)
+	for _, p := range g.Players {
+		if counts[p] == 0 || counts[p] < most {
+			continue
+		}
+		if counts[p] > most {
+			most = counts[p]
+			leaders = leaders[:0]
+		}
+		leaders = append(leaders, p)
+	}
+
+	tally := &Tally{Accused: -1, Counts: counts}
+	if len(leaders) == 1 {
+		tally.Accused = leaders[0]
+	} else {
+		tally.Tied = leaders
+	}
+	g.Tally = tally
+	if tally.Accused == g.Poser {
+		g.State = PoserGuessing
+		return
+	}
+	g.end(&Outcome{Caught: false})
+}
+
+// SubmitGuess records the Poser's guess at the prompt.
+//
+// A guess that matches the prompt (ignoring case and surrounding whitespace) is accepted immediately.
+// Anything else is left to the Muse to judge.
+func (g *Game) SubmitGuess(player int, guess string) error {
+	if g.State != PoserGuessing {
+		return ErrInvalidState
+	}
+
+	if player != g.Poser {
+		return ErrNotPoser
+	}
+
+	guess = strings.TrimSpace(guess)
+	if guess == "" {
+		return ErrBlankGuess
+	}
+	g.Guess = guess
+	if strings.EqualFold(g.Guess, strings.TrimSpace(g.Prompt)) {
+		g.end(&Outcome{Caught: true, GuessCorrect: true})
+		return nil
+	}
+	if _, ok := g.PlayerStates[g.Muse]; !ok {
+		// The Muse has left, so there's nobody to judge
+		g.end(&Outcome{Caught: true, GuessCorrect: false})
+		return nil
+	}
+	g.State = ValidatingGuess
+	return nil
+}
+
+// JudgeGuess records the Muse's ruling on the Poser's guess, ending the game.
+func (g *Game) JudgeGuess(player int, correct bool) error {
+	if g.State != ValidatingGuess {
+		return ErrInvalidState
+	}
+
+	if player != g.Muse {
+		return ErrNotMuse
+	}
+
+	g.end(&Outcome{Caught: true, GuessCorrect: correct})
+	return nil
+}
+
+// RejectGuess ends the game with the Poser's guess counted as wrong,
+// e.g. when the Poser or the Muse runs out of time.
+func (g *Game) RejectGuess() error {
+	if g.State != PoserGuessing && g.State != ValidatingGuess {
+		return ErrInvalidState
+	}
+	g.end(&Outcome{Caught: true, GuessCorrect: false})
+	return nil
+}
+
+// Points returns the points earned by each player in a finished game, keyed by player.
+//
+// If the Poser escaped or guessed the prompt, the Poser and the Muse score.
+// Otherwise, every other Artist scores.
+func (g *Game) Points() map[int]int {
+	points := make(map[int]int)
+	if g.Outcome == nil {
+		return points
+	}
+	if g.Outcome.PoserWins() {
+		points[g.Poser] = poserWinPoints
+		points[g.Muse] = poserWinPoints
+		return points
+	}
+	for _, p := range g.Players {
+		if p != g.Poser && p != g.Muse {
+			points[p] = artistWinPoints
+		}
+	}
+	return points
+}
+
+// end records the outcome of the game and returns to Waiting.
+//
+// Unlike Abort, this leaves the rest of the game intact so the results can be reported.
+func (g *Game) end(outcome *Outcome) {
+	g.Outcome = outcome
+	g.State = Waiting
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// newTestGame starts a game for players 0 to n-1, with player 0 as the Muse,
+// player 1 as the Poser, and player 0 drawing first.
+func newTestGame(t *testing.T, n int, state State) *Game {
+	t.Helper()
+	players := make([]int, n)
+	for i := range players {
+		players[i] = i
+	}
+	g := &Game{State: Waiting, Rounds: 1}
+	if err := g.StartWithMuse(players, 0); err != nil {
+		t.Fatalf("StartWithMuse: %s", err)
+	}
+	g.Poser = 1
+	g.Drawing = 0
+	g.Prompt = "a cat"
+	g.State = state
+	return g
+}
+
+func TestCastVote(t *testing.T) {
+	tests := []struct {
+		name string
+		// Votes already cast
+		votes         map[int]int
+		voter, target int
+		want          error
+		wantState     State
+	}{
+		{"vote", nil, 0, 1, nil, Voting},
+		{"self vote", nil, 2, 2, ErrSelfVote, Voting},
+		{"already voted", map[int]int{2: 1}, 2, 3, ErrAlreadyVoted, Voting},
+		{"target not playing", nil, 2, 9, ErrNoSuchPlayer, Voting},
+		{"voter not playing", nil, 9, 2, ErrNoSuchPlayer, Voting},
+		{"last vote catches the Poser", map[int]int{0: 1, 1: 2, 2: 1}, 3, 1, nil, PoserGuessing},
+		{"last vote misses the Poser", map[int]int{0: 2, 1: 2, 2: 3}, 3, 2, nil, Waiting},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t, 4, Voting)
+			for voter, target := range tt.votes {
+				g.Votes[voter] = target
+			}
+			if err := g.CastVote(tt.voter, tt.target); !errors.Is(err, tt.want) {
+				t.Fatalf("CastVote() = %v, want %v", err, tt.want)
+			}
+			if g.State != tt.wantState {
+				t.Errorf("state = %s, want %s", g.State, tt.wantState)
+			}
+		})
+	}
+
+	t.Run("wrong state", func(t *testing.T) {
+		g := newTestGame(t, 4, Drawing)
+		if err := g.CastVote(0, 1); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("CastVote() = %v, want %v", err, ErrInvalidState)
+		}
+	})
+}
+
+func TestTally(t *testing.T) {
+	tests := []struct {
+		name        string
+		votes       map[int]int
+		wantAccused int
+		wantTied    []int
+		wantState   State
+	}{
+		{"Poser caught", map[int]int{0: 1, 1: 2, 2: 1, 3: 1}, 1, nil, PoserGuessing},
+		{"artist accused", map[int]int{0: 2, 1: 2, 2: 1, 3: 2}, 2, nil, Waiting},
+		// Ties are reported in turn order
+		{"tie", map[int]int{0: 3, 1: 2, 2: 1}, -1, []int{1, 2, 3}, Waiting},
+		{"two-way tie", map[int]int{0: 2, 1: 2, 2: 1, 3: 1}, -1, []int{1, 2}, Waiting},
+		{"no votes", map[int]int{}, -1, []int{}, Waiting},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t, 4, Voting)
+			g.Votes = tt.votes
+			g.tally()
+			if g.Tally.Accused != tt.wantAccused {
+				t.Errorf("Accused = %d, want %d", g.Tally.Accused, tt.wantAccused)
+			}
+			if len(g.Tally.Tied) != len(tt.wantTied) ||
+				(len(tt.wantTied) > 0 && !reflect.DeepEqual(g.Tally.Tied, tt.wantTied)) {
+				t.Errorf("Tied = %v, want %v", g.Tally.Tied, tt.wantTied)
+			}
+			if g.State != tt.wantState {
+				t.Errorf("state = %s, want %s", g.State, tt.wantState)
+			}
+			if g.State == Waiting && (g.Outcome == nil || g.Outcome.Caught) {
+				t.Errorf("Outcome = %+v, want the Poser to escape", g.Outcome)
+			}
+		})
+	}
+}
+
+func TestSetPrompt(t *testing.T) {
+	tests := []struct {
+		name   string
+		prompt string
+		want   error
+	}{
+		{"prompt", "a cat", nil},
+		{"trimmed", "  a cat ", nil},
+		{"empty", "", ErrBlankPrompt},
+		{"blank", " \t ", ErrBlankPrompt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t, 3, GettingPrompt)
+			err := g.SetPrompt(tt.prompt)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("SetPrompt() = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && (g.State != Drawing || g.Prompt != "a cat") {
+				t.Errorf("state %s, prompt %q", g.State, g.Prompt)
+			}
+			if tt.want != nil && g.State != GettingPrompt {
+				t.Errorf("state after rejected prompt = %s, want %s", g.State, GettingPrompt)
+			}
+		})
+	}
+}
+
+func TestSubmitGuess(t *testing.T) {
+	tests := []struct {
+		name      string
+		player    int
+		guess     string
+		want      error
+		wantState State
+	}{
+		{"exact guess", 1, " A Cat ", nil, Waiting},
+		{"guess for the Muse to judge", 1, "a dog", nil, ValidatingGuess},
+		{"blank guess", 1, "  ", ErrBlankGuess, PoserGuessing},
+		{"not the Poser", 2, "a cat", ErrNotPoser, PoserGuessing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t, 3, PoserGuessing)
+			if err := g.SubmitGuess(tt.player, tt.guess); !errors.Is(err, tt.want) {
+				t.Fatalf("SubmitGuess() = %v, want %v", err, tt.want)
+			}
+			if g.State != tt.wantState {
+				t.Errorf("state = %s, want %s", g.State, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestStartWithMuse(t *testing.T) {
+	tests := []struct {
+		name    string
+		players []int
+		muse    int
+		wantErr bool
+	}{
+		{"start", []int{0, 1, 2}, 1, false},
+		{"not enough players", []int{0, 1}, 0, true},
+		{"muse not playing", []int{0, 1, 2}, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Game{State: Waiting, Rounds: 1}
+			err := g.StartWithMuse(tt.players, tt.muse)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("StartWithMuse() = %v, want nil", err)
+				}
+				if g.State != GettingPrompt || g.Muse != tt.muse || g.Poser == g.Muse {
+					t.Errorf("state %s, Muse %d, Poser %d", g.State, g.Muse, g.Poser)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("StartWithMuse() = nil, want error")
+			}
+			// A failed start leaves the game as it was
+			if g.State != Waiting || g.Players != nil || g.PlayerStates != nil {
+				t.Errorf("game changed by failed start: %+v", g)
+			}
+		})
+	}
+}
+
+func TestRemovePlayer(t *testing.T) {
+	tests := []struct {
+		name    string
+		players int
+		state   State
+		// Adjusts the game before the player leaves
+		setup  func(g *Game)
+		player int
+		want   Departure
+		check  func(t *testing.T, g *Game)
+	}{
+		{
+			name: "between games", players: 4, state: Waiting, player: 2,
+			want: DepartureIgnored,
+		},
+		{
+			name: "not playing", players: 4, state: Drawing, player: 7,
+			want: DepartureIgnored,
+		},
+		{
+			name: "artist leaves", players: 4, state: Drawing, player: 3,
+			want: DepartureRemoved,
+			check: func(t *testing.T, g *Game) {
+				if !reflect.DeepEqual(g.Players, []int{0, 1, 2}) {
+					t.Errorf("Players = %v", g.Players)
+				}
+				if g.PlayerStates[2].Index != 2 {
+					t.Errorf("index of player 2 = %d", g.PlayerStates[2].Index)
+				}
+			},
+		},
+		{
+			name: "Poser leaves", players: 4, state: Drawing, player: 1,
+			want: DeparturePoserLeft,
+		},
+		{
+			name: "too few players left", players: 3, state: Drawing, player: 2,
+			want: DepartureNotEnoughPlayers,
+		},
+		{
+			name: "Muse leaves before picking a prompt", players: 4, state: GettingPrompt, player: 0,
+			want: DepartureMuseReassigned,
+			check: func(t *testing.T, g *Game) {
+				if g.Muse == 0 || g.Muse == g.Poser {
+					t.Errorf("new Muse = %d, Poser = %d", g.Muse, g.Poser)
+				}
+				// Player 0 was going to draw first
+				if g.Drawing != 1 {
+					t.Errorf("Drawing = %d, want 1", g.Drawing)
+				}
+			},
+		},
+		{
+			name: "drawer leaves", players: 4, state: Drawing, player: 0,
+			want: DepartureTurnSkipped,
+			check: func(t *testing.T, g *Game) {
+				if g.State != Drawing || g.Drawing != 1 {
+					t.Errorf("state %s, Drawing %d", g.State, g.Drawing)
+				}
+			},
+		},
+		{
+			name: "last drawer leaves", players: 4, state: Drawing, player: 3,
+			setup: func(g *Game) {
+				g.Drawing = 3
+				for _, p := range []int{0, 1, 2} {
+					g.PlayerStates[p].TurnsTaken = 1
+				}
+			},
+			want: DepartureTurnSkipped,
+			check: func(t *testing.T, g *Game) {
+				if g.State != Voting {
+					t.Errorf("state = %s, want %s", g.State, Voting)
+				}
+			},
+		},
+		{
+			name: "last voter leaves", players: 4, state: Voting, player: 3,
+			setup: func(g *Game) {
+				g.Votes = map[int]int{0: 1, 1: 2, 2: 1}
+			},
+			want: DepartureVotingClosed,
+			check: func(t *testing.T, g *Game) {
+				if g.Tally == nil || g.Tally.Accused != 1 || g.State != PoserGuessing {
+					t.Errorf("state %s, Tally %+v", g.State, g.Tally)
+				}
+			},
+		},
+		{
+			name: "Muse leaves before judging", players: 4, state: ValidatingGuess, player: 0,
+			want: DepartureGuessRejected,
+			check: func(t *testing.T, g *Game) {
+				if g.State != Waiting || g.Outcome == nil || g.Outcome.GuessCorrect {
+					t.Errorf("state %s, Outcome %+v", g.State, g.Outcome)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t, tt.players, tt.state)
+			if tt.setup != nil {
+				tt.setup(g)
+			}
+			if got := g.RemovePlayer(tt.player); got != tt.want {
+				t.Fatalf("RemovePlayer() = %v, want %v", got, tt.want)
+			}
+			if tt.check != nil {
+				tt.check(t, g)
+			}
+		})
+	}
+}
+
+func TestPoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome *Outcome
+		want    map[int]int
+	}{
+		{"unfinished", nil, map[int]int{}},
+		{"Poser escapes", &Outcome{Caught: false}, map[int]int{0: 2, 1: 2}},
+		{"Poser guesses the prompt", &Outcome{Caught: true, GuessCorrect: true}, map[int]int{0: 2, 1: 2}},
+		// The Muse doesn't score with the other Artists
+		{"Poser caught", &Outcome{Caught: true, GuessCorrect: false}, map[int]int{2: 1, 3: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t, 4, Waiting)
+			g.Outcome = tt.outcome
+			if got := g.Points(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Points() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidPayload = errors.New("invalid payload")
+var ErrNotHost = errors.New("only the host can do that")
+var ErrNotMuse = errors.New("you are not the Muse")
+var ErrNotPoser = errors.New("you are not the Poser")
+var ErrSpectating = errors.New("spectators can't do that")
+var ErrMuted = errors.New("you have been muted by the host")
+var ErrRateLimited = errors.New("you're sending messages too quickly")
+var ErrLeaksPrompt = errors.New("it gives away the prompt")
+
+// Requirement restricts who may send a type of message.
+type Requirement int
+
+const (
+	// Anyone in the room, including spectators
+	Anyone Requirement = iota
+	// Players with a seat
+	Seated
+	// The room owner
+	Host
+	// The Muse of the current game
+	MuseOnly
+	// The Poser of the current game
+	PoserOnly
+	// The player whose turn it is to draw
+	Drawer
+)
+
+// A messageHandler handles one type of message sent by a client to its room.
+//
+// Checks common to all handlers are done before handle is called:
+// the sender must meet Requires, the game must be in one of States,
+// and the payload must unmarshal into the handler's payload type.
+// Any error handle returns is sent back to the client.
+type messageHandler struct {
+	// What the sender is trying to do, to explain errors, e.g. "start the game"
+	Action string
+	// Game states the message is allowed in, or nil for any state
+	States   []State
+	Requires Requirement
+	// Type of the message's payload, for ProtocolSchema
+	Payload reflect.Type
+	handle  func(room *Room, conn *Connection, data json.RawMessage) error
+}
+
+// messageHandlers maps each message type a client may send to its handler.
+//
+// This is an allowlist: anything not in here is rejected, never relayed to the rest of the room.
+// Add new message types with register.
+var messageHandlers = map[string]messageHandler{}
+
+// register adds a handler for messageType, with payloads of type T.
+// The payload is unmarshalled before handle is called, so handlers take it already typed.
+func register[T any](messageType string, action string, states []State, requires Requirement,
+	handle func(room *Room, conn *Connection, m *T) error) {
+	messageHandlers[messageType] = messageHandler{
+		Action:   action,
+		States:   states,
+		Requires: requires,
+		Payload:  reflect.TypeOf(new(T)).Elem(),
+		handle: func(room *Room, conn *Connection, data json.RawMessage) error {
+			m := new(T)
+			// Some messages have nothing to parse from data
+			if len(data) > 0 && string(data) != "null" {
+				if err := json.Unmarshal(data, m); err != nil {
+					return fmt.Errorf("%w: %s", ErrInvalidPayload, err)
+				}
+			}
+			return handle(room, conn, m)
+		},
+	}
+}
+
+// serverMessages maps each message type the server sends to clients to its payload type, for ProtocolSchema.
+//
+// Add new message types with declare.
+var serverMessages = map[string]reflect.Type{}
+
+// declare adds messageType to the messages the server sends, with payloads of type T.
+func declare[T any](messageType string) {
+	serverMessages[messageType] = reflect.TypeOf(new(T)).Elem()
+}
+
+// NoPayload is the payload type of messages that don't carry any data.
+type NoPayload struct{}
+
+func init() {
+	register("chat", "send your message", nil, Anyone, handleChat)
+	register("set_name", "set your name", nil, Anyone, handleSetName)
+	register("set_color", "change your color", []State{Waiting}, Seated, handleSetColor)
+	register("clear", "clear the canvas", nil, Host, handleClear)
+	register("done", "end your turn", []State{Drawing}, Drawer, handleDone)
+	// Draw checks for itself who can draw, and only returns an error once per stroke rather than for every segment
+	register("draw", "draw", nil, Anyone, handleDraw)
+	register("prompt", "set the prompt", []State{GettingPrompt}, MuseOnly, handlePrompt)
+	register("transfer_host", "transfer host", nil, Host, handleTransferHost)
+	register("kick", "kick player", nil, Host, handleKick)
+	register("ban", "ban player", nil, Host, handleBan)
+	register("mute", "mute player", nil, Host, handleMute)
+	register("vote", "vote", []State{Voting}, Seated, handleVote)
+	register("guess", "guess the prompt", []State{PoserGuessing}, PoserOnly, handleGuess)
+	register("judge", "judge the guess", []State{ValidatingGuess}, MuseOnly, handleJudge)
+	register("match", "configure the match", nil, Host, handleMatch)
+	// Start checks the state itself, so a start mid-game gets game_in_progress rather than invalid_state
+	register("start", "start the game", nil, Host, handleStart)
+
+	declare[ConnectionMessage]("connection")
+	declare[PlayersMessage]("players")
+	declare[QueueMessage]("queue")
+	declare[ChatMessage]("chat")
+	declare[DrawMessage]("draw")
+	declare[StrokesMessage]("strokes")
+	declare[NoPayload]("clear")
+	declare[RoleMessage]("role")
+	declare[StateMessage]("state")
+	declare[TurnMessage]("turn")
+	declare[TallyMessage]("tally")
+	declare[GuessMessage]("guess")
+	declare[OutcomeMessage]("outcome")
+	declare[ScoresMessage]("scores")
+	declare[MatchMessage]("match")
+	declare[MatchOverMessage]("match_over")
+	declare[NotificationMessage]("notification")
+	declare[ErrorMessage]("error")
+}
+
+// HandleMessage dispatches a message from conn to the handler for its type,
+// replying with an error message if it's rejected.
+func HandleMessage(room *Room, conn *Connection, m *Message) {
+	handler, ok := messageHandlers[m.Type]
+	if !ok {
+		log.Printf("%s:%s: unexpected message type %q", room.ID, conn.ID, m.Type)
+		conn.SendError(m.RequestID, CodeUnknownType, fmt.Sprintf("Unknown message type %q.", m.Type))
+		return
+	}
+	if err := handler.Handle(room, conn, m.Data); err != nil {
+		code := CodeOf(err)
+		if code == CodeInvalidPayload || code == CodeInternal {
+			log.Printf("%s:%s: error handling %s message: %s", room.ID, conn.ID, m.Type, err)
+		}
+		conn.SendError(m.RequestID, code, fmt.Sprintf("Couldn't %s: %s.", handler.Action, err))
+	}
+}
+
+// Handle checks that conn may send this message right now, then handles it.
+func (h messageHandler) Handle(room *Room, conn *Connection, data json.RawMessage) error {
+	if err := room.authorize(conn, h.States, h.Requires); err != nil {
+		return err
+	}
+	return h.handle(room, conn, data)
+}
+
+// authorize checks that the game is in one of states, and that conn meets requires.
+func (r *Room) authorize(conn *Connection, states []State, requires Requirement) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	g := r.Game
+	if len(states) > 0 {
+		allowed := false
+		for _, state := range states {
+			allowed = allowed || g.State == state
+		}
+		if !allowed {
+			return fmt.Errorf("%w: can't do that while %s", ErrInvalidState, g.State)
+		}
+	}
+	player := conn.PlayerNumber - 1
+	switch requires {
+	case Seated:
+		if conn.IsSpectator() {
+			return ErrSpectating
+		}
+	case Host:
+		if conn.ID != r.Owner {
+			return ErrNotHost
+		}
+	case MuseOnly:
+		if conn.IsSpectator() || player != g.Muse {
+			return ErrNotMuse
+		}
+	case PoserOnly:
+		if conn.IsSpectator() || player != g.Poser {
+			return ErrNotPoser
+		}
+	case Drawer:
+		if conn.IsSpectator() || player != g.Drawing {
+			return ErrNotYourTurn
+		}
+	}
+	return nil
+}
+
+func handleChat(room *Room, conn *Connection, m *ChatMessage) error {
+	if room.IsMuted(conn) {
+		return ErrMuted
+	}
+	if !conn.chatLimiter.Allow() {
+		return ErrRateLimited
+	}
+	text, err := SanitizeChat(m.Text)
+	if err != nil {
+		return err
+	}
+	if room.LeaksPrompt(conn, text) {
+		return ErrLeaksPrompt
+	}
+	m.Text = text
+	// Set user ID, ignore anything client may have set.
+	m.User = conn.ID
+	m.PlayerNumber = conn.PlayerNumber
+	m.Name = room.Name(conn)
+	// Set message ID - these have to be distinct on the client side.
+	m.ID = fmt.Sprintf("msg-%s", uuid.New().String())
+	log.Printf("%s:%s: %s", room.ID, conn.ID, m.Text)
+	return room.Chat(m)
+}
+
+func handleSetName(room *Room, conn *Connection, m *NameMessage) error {
+	return room.SetName(conn, m.Name)
+}
+
+func handleSetColor(room *Room, conn *Connection, m *ColorMessage) error {
+	return room.SetColor(conn, m.Color)
+}
+
+func handleClear(room *Room, conn *Connection, _ *NoPayload) error {
+	return room.Clear()
+}
+
+// User finished their turn
+func handleDone(room *Room, conn *Connection, _ *NoPayload) error {
+	return room.EndTurn(conn.PlayerNumber - 1)
+}
+
+func handleDraw(room *Room, conn *Connection, m *DrawMessage) error {
+	return room.Draw(conn, m)
+}
+
+func handlePrompt(room *Room, conn *Connection, m *PromptMessage) error {
+	return room.SetPrompt(m.Prompt)
+}
+
+func handleTransferHost(room *Room, conn *Connection, m *TransferHostMessage) error {
+	return room.TransferOwner(conn, m.PlayerNumber)
+}
+
+func handleKick(room *Room, conn *Connection, m *ModerateMessage) error {
+	return room.Kick(conn, m.PlayerNumber, m.ID, false)
+}
+
+func handleBan(room *Room, conn *Connection, m *ModerateMessage) error {
+	return room.Kick(conn, m.PlayerNumber, m.ID, true)
+}
+
+func handleMute(room *Room, conn *Connection, m *ModerateMessage) error {
+	return room.Mute(conn, m.PlayerNumber, m.ID, m.Muted)
+}
+
+func handleVote(room *Room, conn *Connection, m *VoteMessage) error {
+	return room.CastVote(conn.PlayerNumber-1, m.PlayerNumber-1)
+}
+
+func handleGuess(room *Room, conn *Connection, m *GuessMessage) error {
+	return room.SubmitGuess(conn.PlayerNumber-1, m.Guess)
+}
+
+func handleJudge(room *Room, conn *Connection, m *JudgeMessage) error {
+	return room.JudgeGuess(conn.PlayerNumber-1, m.Correct)
+}
+
+func handleMatch(room *Room, conn *Connection, m *MatchMessage) error {
+	return room.ConfigureMatch(m.TargetScore, m.TargetGames)
+}
+
+func handleStart(room *Room, conn *Connection, _ *NoPayload) error {
+	return room.Start()
+}
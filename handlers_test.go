@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// newTestRoom creates a room with players seated in it, using connections without a websocket.
+func newTestRoom(t *testing.T, players int) (*Room, []*Connection) {
+	t.Helper()
+	room, err := NewRoom("test", DefaultRoomConfig())
+	if err != nil {
+		t.Fatalf("NewRoom: %s", err)
+	}
+	conns := make([]*Connection, players)
+	for i := range conns {
+		conns[i] = newConnection(nil, fmt.Sprintf("10.0.0.%d", i+1))
+		if err := room.Add(conns[i]); err != nil {
+			t.Fatalf("Add: %s", err)
+		}
+	}
+	return room, conns
+}
+
+// addSpectator adds a connection to room once its seats are taken.
+func addSpectator(t *testing.T, room *Room) *Connection {
+	t.Helper()
+	conn := newConnection(nil, "10.0.1.1")
+	room.Spectators = append(room.Spectators, conn)
+	return conn
+}
+
+// sent drains and parses the messages queued for conn.
+func sent(t *testing.T, conn *Connection) []*Message {
+	t.Helper()
+	var messages []*Message
+	for {
+		select {
+		case m := <-conn.send:
+			message, err := ParseMessage(m.data)
+			if err != nil {
+				t.Fatalf("ParseMessage: %s", err)
+			}
+			// Anything sent must be in the protocol schema and types
+			if _, ok := serverMessages[message.Type]; !ok {
+				t.Errorf("sent undeclared message type %q", message.Type)
+			}
+			messages = append(messages, message)
+		default:
+			return messages
+		}
+	}
+}
+
+// lastError gets the code of the last error sent to conn, or the empty string if there wasn't one.
+func lastError(t *testing.T, conn *Connection) ErrorCode {
+	t.Helper()
+	var code ErrorCode
+	for _, m := range sent(t, conn) {
+		if m.Type != "error" {
+			continue
+		}
+		e := &ErrorMessage{}
+		if err := json.Unmarshal(m.Data, e); err != nil {
+			t.Fatalf("unmarshalling error message: %s", err)
+		}
+		code = e.Code
+	}
+	return code
+}
+
+// handle sends a message of type messageType from conn, returning the code of any error sent back.
+func handle(t *testing.T, room *Room, conn *Connection, messageType string, data any) ErrorCode {
+	t.Helper()
+	sent(t, conn)
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("marshalling %s: %s", messageType, err)
+	}
+	HandleMessage(room, conn, &Message{Type: messageType, Data: raw})
+	return lastError(t, conn)
+}
+
+// startGame starts a game in room, and returns the index of the Muse.
+func startGame(t *testing.T, room *Room) int {
+	t.Helper()
+	if err := room.Start(); err != nil {
+		t.Fatalf("Start: %s", err)
+	}
+	stopDeadline(room)
+	return room.Game.Muse
+}
+
+// stopDeadline cancels the countdown in room, so it can't change the game mid-test.
+func stopDeadline(room *Room) {
+	room.mux.Lock()
+	defer room.mux.Unlock()
+	room.stopDeadlineUnsafe()
+}
+
+func TestAuthorize(t *testing.T) {
+	room, conns := newTestRoom(t, 3)
+	spectator := addSpectator(t, room)
+	// Player #1 is the host
+	host, other := conns[0], conns[1]
+	room.Game.State = Drawing
+	room.Game.Muse = 1
+	room.Game.Poser = 2
+	room.Game.Drawing = 0
+
+	tests := []struct {
+		name     string
+		conn     *Connection
+		states   []State
+		requires Requirement
+		want     error
+	}{
+		{"anyone, any state", spectator, nil, Anyone, nil},
+		{"allowed state", other, []State{Waiting, Drawing}, Anyone, nil},
+		{"wrong state", host, []State{Waiting}, Anyone, ErrInvalidState},
+		{"seated player", other, nil, Seated, nil},
+		{"seated spectator", spectator, nil, Seated, ErrSpectating},
+		{"host", host, nil, Host, nil},
+		{"not host", other, nil, Host, ErrNotHost},
+		{"muse", conns[1], nil, MuseOnly, nil},
+		{"not muse", conns[2], nil, MuseOnly, ErrNotMuse},
+		{"spectator as muse", spectator, nil, MuseOnly, ErrNotMuse},
+		{"poser", conns[2], nil, PoserOnly, nil},
+		{"not poser", conns[1], nil, PoserOnly, ErrNotPoser},
+		{"drawer", conns[0], nil, Drawer, nil},
+		{"not drawer", conns[1], nil, Drawer, ErrNotYourTurn},
+		// Spectators have player number 0, which would be index -1
+		{"spectator as drawer", spectator, nil, Drawer, ErrNotYourTurn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := room.authorize(tt.conn, tt.states, tt.requires)
+			if tt.want == nil && err != nil {
+				t.Errorf("authorize() = %v, want nil", err)
+			} else if tt.want != nil && CodeOf(err) != CodeOf(tt.want) {
+				t.Errorf("authorize() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleMessageErrors(t *testing.T) {
+	room, conns := newTestRoom(t, 3)
+	spectator := addSpectator(t, room)
+	host := conns[0]
+
+	tests := []struct {
+		name        string
+		conn        *Connection
+		messageType string
+		data        any
+		want        ErrorCode
+	}{
+		{"unknown type", host, "bogus", nil, CodeUnknownType},
+		{"bad payload", host, "chat", "not an object", CodeInvalidPayload},
+		{"empty chat", host, "chat", ChatMessage{Text: "  "}, CodeEmptyMessage},
+		{"chat", host, "chat", ChatMessage{Text: "hello"}, ""},
+		{"spectator chat", spectator, "chat", ChatMessage{Text: "hello"}, ""},
+		{"spectator draw", spectator, "draw", DrawMessage{Stroke: StrokeStart}, CodeSpectating},
+		{"doodle while waiting", conns[1], "draw", DrawMessage{Stroke: StrokeStart}, ""},
+		{"unknown stroke phase", conns[1], "draw", DrawMessage{Stroke: "sideways"}, CodeInvalidStroke},
+		{"not host", conns[1], "start", nil, CodeNotHost},
+		{"wrong state", host, "vote", VoteMessage{PlayerNumber: 2}, CodeInvalidState},
+		{"kick self", host, "kick", ModerateMessage{PlayerNumber: 1}, CodeSelfTarget},
+		{"kick nobody", host, "kick", ModerateMessage{PlayerNumber: 9}, CodeNoSuchPlayer},
+		{"mute unknown ID", host, "mute", ModerateMessage{ID: "user-nobody", Muted: true}, CodeNoSuchPlayer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := handle(t, room, tt.conn, tt.messageType, tt.data); got != tt.want {
+				t.Errorf("error code = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleMuteSpectator(t *testing.T) {
+	room, conns := newTestRoom(t, 3)
+	spectator := addSpectator(t, room)
+
+	if got := handle(t, room, conns[0], "mute", ModerateMessage{ID: spectator.ID, Muted: true}); got != "" {
+		t.Fatalf("muting spectator: error code %q", got)
+	}
+	if got := handle(t, room, spectator, "chat", ChatMessage{Text: "hello"}); got != CodeMuted {
+		t.Errorf("muted spectator chat: error code = %q, want %q", got, CodeMuted)
+	}
+}
+
+func TestHandleStartNotEnoughPlayers(t *testing.T) {
+	room, conns := newTestRoom(t, minPlayers-1)
+	if got := handle(t, room, conns[0], "start", nil); got != CodeNotEnoughPlayers {
+		t.Errorf("error code = %q, want %q", got, CodeNotEnoughPlayers)
+	}
+}
+
+func TestHandleStartInProgress(t *testing.T) {
+	room, conns := newTestRoom(t, minPlayers)
+	startGame(t, room)
+	if got := handle(t, room, conns[0], "start", nil); got != CodeGameInProgress {
+		t.Errorf("error code = %q, want %q", got, CodeGameInProgress)
+	}
+}
+
+func TestHandlePrompt(t *testing.T) {
+	room, conns := newTestRoom(t, 3)
+	muse := conns[startGame(t, room)]
+
+	if got := handle(t, room, muse, "prompt", PromptMessage{Prompt: "   "}); got != CodeBlankPrompt {
+		t.Errorf("blank prompt: error code = %q, want %q", got, CodeBlankPrompt)
+	}
+	if room.Game.State != GettingPrompt {
+		t.Fatalf("state after blank prompt = %s, want %s", room.Game.State, GettingPrompt)
+	}
+	if got := handle(t, room, muse, "prompt", PromptMessage{Prompt: "a cat"}); got != "" {
+		t.Fatalf("prompt: error code %q", got)
+	}
+	if room.Game.State != Drawing {
+		t.Fatalf("state after prompt = %s, want %s", room.Game.State, Drawing)
+	}
+	// A duplicate prompt is rejected, rather than ending the game
+	if got := handle(t, room, muse, "prompt", PromptMessage{Prompt: "a dog"}); got != CodeInvalidState {
+		t.Errorf("duplicate prompt: error code = %q, want %q", got, CodeInvalidState)
+	}
+	if room.Game.State != Drawing || room.Game.Prompt != "a cat" {
+		t.Errorf("after duplicate prompt: state %s, prompt %q", room.Game.State, room.Game.Prompt)
+	}
+}
+
+func TestHandleDrawing(t *testing.T) {
+	room, conns := newTestRoom(t, 3)
+	muse := conns[startGame(t, room)]
+	if got := handle(t, room, muse, "prompt", PromptMessage{Prompt: "a cat"}); got != "" {
+		t.Fatalf("prompt: error code %q", got)
+	}
+	stopDeadline(room)
+	drawer := conns[room.Game.Drawing]
+	waiting := conns[(room.Game.Drawing+1)%len(conns)]
+
+	if got := handle(t, room, waiting, "draw", DrawMessage{Stroke: StrokeStart}); got != CodeNotYourTurn {
+		t.Errorf("draw out of turn: error code = %q, want %q", got, CodeNotYourTurn)
+	}
+	// Only the start of a stroke is worth complaining about
+	if got := handle(t, room, waiting, "draw", DrawMessage{Stroke: StrokeSegment}); got != "" {
+		t.Errorf("segment out of turn: error code = %q, want none", got)
+	}
+	if got := handle(t, room, waiting, "done", nil); got != CodeNotYourTurn {
+		t.Errorf("done out of turn: error code = %q, want %q", got, CodeNotYourTurn)
+	}
+	if got := handle(t, room, drawer, "draw", DrawMessage{Stroke: StrokeStart}); got != "" {
+		t.Errorf("draw: error code %q", got)
+	}
+	if got := handle(t, room, drawer, "draw", DrawMessage{Stroke: StrokeStart}); got != CodeInvalidStroke {
+		t.Errorf("second stroke start: error code = %q, want %q", got, CodeInvalidStroke)
+	}
+	if got := handle(t, room, drawer, "done", nil); got != "" {
+		t.Errorf("done: error code %q", got)
+	}
+	stopDeadline(room)
+	if room.Game.Drawing != waiting.PlayerNumber-1 {
+		t.Errorf("drawing after done = %d, want %d", room.Game.Drawing, waiting.PlayerNumber-1)
+	}
+}
+
+func TestHandleVote(t *testing.T) {
+	room, conns := newTestRoom(t, 3)
+	startGame(t, room)
+	room.Game.State = Voting
+
+	tests := []struct {
+		name  string
+		voter *Connection
+		vote  int
+		want  ErrorCode
+	}{
+		{"self vote", conns[0], 1, CodeSelfVote},
+		{"no such player", conns[0], 9, CodeNoSuchPlayer},
+		{"vote", conns[0], 2, ""},
+		{"vote again", conns[0], 3, CodeAlreadyVoted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := handle(t, room, tt.voter, "vote", VoteMessage{PlayerNumber: tt.vote}); got != tt.want {
+				t.Errorf("error code = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,22 +1,47 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"log"
 	"net/http"
+	"os"
 
 	"github.com/gorilla/mux"
 )
 
 func main() {
+	schema := flag.Bool("schema", false, "print the JSON Schema of the websocket protocol, then exit")
+	types := flag.Bool("types", false, "print TypeScript types for the websocket protocol, then exit")
+	flag.Parse()
+	if *schema {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(ProtocolSchema()); err != nil {
+			log.Fatalf("failed to write protocol schema: %s", err)
+		}
+		return
+	}
+	if *types {
+		if _, err := os.Stdout.WriteString(ProtocolTypeScript()); err != nil {
+			log.Fatalf("failed to write protocol types: %s", err)
+		}
+		return
+	}
+
+	LoadPages()
 	http.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir("frontend/dist/assets/"))))
 
+	server := NewServer()
+
 	r := mux.NewRouter()
 	r.HandleFunc("/", HomeHandler).Methods("GET")
-	r.HandleFunc("/", NewRoomHandler).Methods("POST")
+	r.HandleFunc("/", server.NewRoomHandler).Methods("POST")
+	r.HandleFunc("/rooms", server.RoomListHandler).Methods("GET")
+	r.HandleFunc("/protocol.json", ProtocolHandler).Methods("GET")
 	r.HandleFunc("/room/{id:.*}", RoomHandler)
 	//r.HandleFunc("/gallery/{id:[0-9]+}", GalleryHandler)
 
-	server := NewServer()
 	r.HandleFunc("/ws/{room}", server.HandleWebsocket)
 
 	http.Handle("/", r)
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+)
+
+// Upper bounds for match configuration, just to keep things sane.
+const (
+	maxTargetScore = 50
+	maxTargetGames = 20
+)
+
+var ErrInvalidMatch = errors.New("invalid match configuration")
+
+// Match is a series of games played until a player reaches a target score,
+// or until a number of games have been played, whichever comes first.
+//
+// A target of zero disables that condition. With both disabled, the match never ends,
+// and scores simply accumulate across games.
+type Match struct {
+	// Points needed to win the match
+	TargetScore int
+	// Number of games in the match
+	TargetGames int
+	// Games finished so far in this match
+	GamesPlayed int
+	// Player who was Muse in the last game, or -1 if none yet
+	LastMuse int
+}
+
+func NewMatch() *Match {
+	return &Match{LastMuse: -1}
+}
+
+// Configure sets the targets for the match.
+func (m *Match) Configure(targetScore, targetGames int) error {
+	if targetScore < 0 || targetScore > maxTargetScore {
+		return ErrInvalidMatch
+	}
+	if targetGames < 0 || targetGames > maxTargetGames {
+		return ErrInvalidMatch
+	}
+	m.TargetScore = targetScore
+	m.TargetGames = targetGames
+	return nil
+}
+
+// NextMuse picks the Muse for the next game from players, which must be sorted.
+//
+// The role rotates through players in order, starting from a random player in the first game.
+func (m *Match) NextMuse(players []int) int {
+	if m.LastMuse < 0 {
+		return players[rand.Intn(len(players))]
+	}
+	for _, p := range players {
+		if p > m.LastMuse {
+			return p
+		}
+	}
+	// Wrap around
+	return players[0]
+}
+
+// IsOver reports whether the match has ended, given the highest score of any player.
+func (m *Match) IsOver(topScore int) bool {
+	if m.TargetScore > 0 && topScore >= m.TargetScore {
+		return true
+	}
+	return m.TargetGames > 0 && m.GamesPlayed >= m.TargetGames
+}
+
+// Reset starts a new match with the same targets.
+func (m *Match) Reset() {
+	m.GamesPlayed = 0
+}
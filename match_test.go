@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMatchConfigure(t *testing.T) {
+	tests := []struct {
+		name                     string
+		targetScore, targetGames int
+		want                     error
+	}{
+		{"both targets", 10, 5, nil},
+		{"endless", 0, 0, nil},
+		{"largest", maxTargetScore, maxTargetGames, nil},
+		{"negative score", -1, 5, ErrInvalidMatch},
+		{"negative games", 10, -1, ErrInvalidMatch},
+		{"score too high", maxTargetScore + 1, 5, ErrInvalidMatch},
+		{"too many games", 10, maxTargetGames + 1, ErrInvalidMatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMatch()
+			err := m.Configure(tt.targetScore, tt.targetGames)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Configure() = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && (m.TargetScore != tt.targetScore || m.TargetGames != tt.targetGames) {
+				t.Errorf("targets = %d, %d", m.TargetScore, m.TargetGames)
+			}
+		})
+	}
+}
+
+func TestNextMuse(t *testing.T) {
+	tests := []struct {
+		name     string
+		lastMuse int
+		players  []int
+		want     int
+	}{
+		{"next in order", 1, []int{0, 1, 2, 3}, 2},
+		{"wraps around", 3, []int{0, 1, 2, 3}, 0},
+		// The last Muse may have left, or someone may have taken an empty seat
+		{"skips empty seats", 1, []int{0, 1, 4, 6}, 4},
+		{"last Muse left", 2, []int{0, 1, 3}, 3},
+		{"last Muse left at the end", 5, []int{0, 1, 3}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMatch()
+			m.LastMuse = tt.lastMuse
+			if got := m.NextMuse(tt.players); got != tt.want {
+				t.Errorf("NextMuse() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("first game", func(t *testing.T) {
+		players := []int{0, 2, 5}
+		got := NewMatch().NextMuse(players)
+		if got != 0 && got != 2 && got != 5 {
+			t.Errorf("NextMuse() = %d, want one of %v", got, players)
+		}
+	})
+}
+
+func TestMatchIsOver(t *testing.T) {
+	tests := []struct {
+		name                     string
+		targetScore, targetGames int
+		gamesPlayed, topScore    int
+		want                     bool
+	}{
+		{"endless", 0, 0, 100, 100, false},
+		{"below target score", 10, 0, 5, 9, false},
+		{"target score reached", 10, 0, 5, 10, true},
+		{"target score passed", 10, 0, 5, 11, true},
+		{"games left", 0, 5, 4, 100, false},
+		{"games played", 0, 5, 5, 0, true},
+		{"score reached first", 10, 5, 2, 10, true},
+		{"games played first", 10, 5, 5, 3, true},
+		{"neither", 10, 5, 4, 9, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMatch()
+			if err := m.Configure(tt.targetScore, tt.targetGames); err != nil {
+				t.Fatalf("Configure: %s", err)
+			}
+			m.GamesPlayed = tt.gamesPlayed
+			if got := m.IsOver(tt.topScore); got != tt.want {
+				t.Errorf("IsOver(%d) = %t, want %t", tt.topScore, got, tt.want)
+			}
+		})
+	}
+}
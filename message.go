@@ -6,24 +6,44 @@ import (
 	"time"
 )
 
+// ProtocolVersion is bumped whenever messages change in a way older clients can't handle.
+//
+// Clients send the version they speak when connecting, and are turned away unless it's
+// between MinProtocolVersion and ProtocolVersion. See ProtocolSchema for the messages themselves.
+const (
+	ProtocolVersion    = 1
+	MinProtocolVersion = 1
+)
+
 // Message provides a wrapper struct for parsing and sending messages to the client.
 //
 // It's really a union type, but Go doesn't have those,
 // so we're using a struct with a type annotation and a raw JSON field.
+//
+// Room events (chat, draw, state and turn messages) are stamped by the server; see MakeEvent.
 type Message struct {
 	Type string          `json:"type"`
 	Data json.RawMessage `json:"data"`
+	// Sequence number of the event within its room, starting from 1
+	Seq int64 `json:"seq,omitempty"`
+	// When the server sent the event, in milliseconds since the Unix epoch
+	Timestamp int64 `json:"timestamp,omitempty"`
+	// Optional ID chosen by the client for a message it sends.
+	// If the server rejects the message, its error message carries the same ID.
+	RequestID string `json:"requestId,omitempty"`
 }
 
 // ChatMessage ferries chat info between users.
 type ChatMessage struct {
 	ID           string `json:"id"`
 	PlayerNumber int    `json:"playerNumber"`
-	//TODO sanitize this
+	// Cleaned up by the server; see SanitizeChat
 	Text string `json:"text"`
-	//TODO can I parse a timestamp as a time.Time?
-	Timestamp int    `json:"timestamp"`
+	// Milliseconds since the Unix epoch, set by the server
+	Timestamp int64  `json:"timestamp"`
 	User      string `json:"user"`
+	// Display name of the sender, or empty if they haven't picked one
+	Name string `json:"name"`
 }
 
 // ConnectionMessage is used to welcome a new user to the game,
@@ -31,30 +51,86 @@ type ChatMessage struct {
 //
 // ID and number aren't equivalent, since player N could leave the room and
 // be replaced by someone else with a different ID.
+//
+// Token lets the client reclaim its seat if its connection drops; see Room.Resume.
+//
+// Protocol is the protocol version the server speaks; see ProtocolVersion.
+//
+// Palette lists the colors players can pick from with set_color.
+//
+// Spectators have player number 0 and no token.
+// They get a new ConnectionMessage once they're given a seat.
 type ConnectionMessage struct {
-	ID           string `json:"id"`
-	PlayerNumber int    `json:"playerNumber"`
+	ID           string   `json:"id"`
+	PlayerNumber int      `json:"playerNumber"`
+	Token        string   `json:"token"`
+	Spectator    bool     `json:"spectator"`
+	Palette      []string `json:"palette"`
+	Protocol     int      `json:"protocol"`
+}
+
+// QueueMessage tells a spectator their place in line for a seat, starting from 1.
+type QueueMessage struct {
+	Position int `json:"position"`
 }
 
 // DrawMessage simply forwards the coordinates of a draw event to the client.
 //
-// Draw events are broken up into single strokes of a larger vector.
+// Draw events are broken up into single segments of a larger stroke.
+// Stroke marks where each segment falls in its stroke, so the server can enforce one stroke per turn.
 type DrawMessage struct {
-	LastX        int `json:"lastX"`
-	LastY        int `json:"lastY"`
-	X            int `json:"x"`
-	Y            int `json:"y"`
-	PlayerNumber int `json:"playerNumber"`
+	LastX        int         `json:"lastX"`
+	LastY        int         `json:"lastY"`
+	X            int         `json:"x"`
+	Y            int         `json:"y"`
+	PlayerNumber int         `json:"playerNumber"`
+	Stroke       StrokePhase `json:"stroke"`
+	// Color of the player who drew this, set by the server
+	Color string `json:"color"`
 }
 
-// TurnMessage indicates which client is currently drawing.
+// StrokesMessage replays everything drawn on the canvas so far, in order.
+//
+// This is sent when a client joins, so late joiners see the same picture as everyone else.
+type StrokesMessage struct {
+	Strokes []*DrawMessage `json:"strokes"`
+}
+
+// TurnMessage indicates which client is currently drawing, and how long they have.
 type TurnMessage struct {
 	PlayerNumber int `json:"playerNumber"`
+	// When the turn's countdown started
+	Started time.Time `json:"started"`
+	// Length of the turn in milliseconds, or zero if there's no limit
+	Duration int64 `json:"duration"`
 }
 
 // PlayersMessage notifies a client of the current players in the game.
 type PlayersMessage struct {
 	IDs []string `json:"ids"`
+	// Display names, by player number like IDs; empty if a player hasn't picked one
+	Names []string `json:"names"`
+	// Drawing colors, by player number like IDs
+	Colors []string `json:"colors"`
+	// Player number of the room owner, who can start games, or 0 if there isn't one
+	Owner int `json:"owner"`
+	// Number of spectators watching the room
+	Spectators int `json:"spectators"`
+}
+
+// TransferHostMessage, sent by the room owner to the server, hands ownership to another player.
+type TransferHostMessage struct {
+	PlayerNumber int `json:"playerNumber"`
+}
+
+// NameMessage, sent by a client to the server, sets the client's display name.
+type NameMessage struct {
+	Name string `json:"name"`
+}
+
+// ColorMessage, sent by a player to the server, picks their drawing color from the palette.
+type ColorMessage struct {
+	Color string `json:"color"`
 }
 
 // PromptMessage, sent by the Muse to the server, contains the Muse's prompt.
@@ -68,8 +144,106 @@ type RoleMessage struct {
 }
 
 // StateMessage notifies a client of the current state of the game.
+//
+// For states with a deadline, it also includes the countdown for that state.
+// During Drawing, this is the countdown for the current turn.
 type StateMessage struct {
 	State State `json:"state"`
+	// When the countdown started
+	Started time.Time `json:"started"`
+	// Length of the countdown in milliseconds, or zero if there's no limit
+	Duration int64 `json:"duration"`
+}
+
+// ModerateMessage, sent by the room owner to the server, kicks, bans, or mutes a player.
+type ModerateMessage struct {
+	PlayerNumber int `json:"playerNumber"`
+	// Connection ID of the target, used instead of PlayerNumber if set.
+	// Spectators don't have a player number, so this is how to moderate them.
+	ID string `json:"id,omitempty"`
+	// Only used by mute: true to mute the player, false to unmute them
+	Muted bool `json:"muted"`
+}
+
+// VoteMessage, sent by a player to the server, accuses another player of being the Poser.
+type VoteMessage struct {
+	PlayerNumber int `json:"playerNumber"`
+}
+
+// TallyMessage reveals the result of the vote to all clients.
+type TallyMessage struct {
+	// Player number of the accused, or 0 if the vote was tied.
+	Accused int `json:"accused"`
+	// Map of player number -> votes received
+	Votes map[int]int `json:"votes"`
+	// Player numbers tied for the most votes, if any.
+	Tied []int `json:"tied"`
+}
+
+// GuessMessage carries the Poser's guess at the prompt.
+//
+// The Poser sends this to the server, which then shares it with all clients so the Muse can judge it.
+type GuessMessage struct {
+	Guess string `json:"guess"`
+}
+
+// JudgeMessage, sent by the Muse to the server, rules on whether the Poser's guess was correct.
+type JudgeMessage struct {
+	Correct bool `json:"correct"`
+}
+
+// OutcomeMessage reveals the prompt, the Poser, and the winner at the end of a game.
+type OutcomeMessage struct {
+	Prompt string `json:"prompt"`
+	// Player number of the Muse
+	Muse int `json:"muse"`
+	// Player number of the Poser
+	Poser int `json:"poser"`
+	// Whether the vote accused the Poser
+	Caught bool `json:"caught"`
+	// Poser's guess at the prompt, if they were caught
+	Guess        string `json:"guess"`
+	GuessCorrect bool   `json:"guessCorrect"`
+	// Winning role: Poser if the Poser escaped or guessed correctly, otherwise Artist
+	Winner Role `json:"winner"`
+}
+
+// ScoresMessage notifies a client of the room's scoreboard.
+type ScoresMessage struct {
+	Scores []Score `json:"scores"`
+}
+
+// Score is a single player's entry in a ScoresMessage.
+type Score struct {
+	ID           string `json:"id"`
+	PlayerNumber int    `json:"playerNumber"`
+	Score        int    `json:"score"`
+}
+
+// MatchMessage configures a match (from the room owner) or reports its progress (from the server).
+//
+// A target of zero disables that condition.
+type MatchMessage struct {
+	TargetScore int `json:"targetScore"`
+	TargetGames int `json:"targetGames"`
+	// Ignored when sent by a client
+	GamesPlayed int `json:"gamesPlayed"`
+}
+
+// MatchOverMessage declares the winner of a match, along with the final scores.
+type MatchOverMessage struct {
+	// Player numbers of the winners; more than one if tied
+	Winners []int   `json:"winners"`
+	Scores  []Score `json:"scores"`
+}
+
+// ErrorMessage tells a client that the server rejected a message it sent, e.g. one of an unknown type.
+//
+// If the client gave that message a request ID, the error message's envelope carries it too.
+type ErrorMessage struct {
+	Code ErrorCode `json:"code"`
+	// Human-readable explanation, suitable for showing to the player
+	Message string `json:"message"`
 }
 
 // NotificationMessage is used to provide messages from the server to the client.
@@ -82,14 +256,21 @@ type NotificationMessage struct {
 	IsError   bool      `json:"isError"`
 }
 
+// RoomSummary describes a public room for the room listing on the home page.
+type RoomSummary struct {
+	ID      string `json:"id"`
+	Players int    `json:"players"`
+	Size    int    `json:"size"`
+	State   State  `json:"state"`
+}
+
 // ParseMessage unwraps a Message from the client, but doesn't try to parse the inner data.
-func ParseMessage(bs []byte) (messageType string, data []byte, err error) {
-	var message Message
-	err = json.Unmarshal(bs, &message)
-	if err != nil {
-		return "", nil, err
+func ParseMessage(bs []byte) (*Message, error) {
+	message := &Message{}
+	if err := json.Unmarshal(bs, message); err != nil {
+		return nil, err
 	}
-	return message.Type, message.Data, nil
+	return message, nil
 }
 
 // MakeMessage wraps a message in a Message struct, then marshals to JSON bytes
@@ -105,3 +286,23 @@ func MakeMessage[T any](messageType string, message T) ([]byte, error) {
 	}
 	return bs, nil
 }
+
+// MakeEvent is like MakeMessage, but stamps the message with a sequence number and the current time.
+//
+// Clients can use these to order events and to tell if they've missed any.
+func MakeEvent[T any](seq int64, messageType string, message T) ([]byte, error) {
+	rawJson, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling message of type %T to data: %w", message, err)
+	}
+	bs, err := json.Marshal(&Message{
+		Type:      messageType,
+		Data:      rawJson,
+		Seq:       seq,
+		Timestamp: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling message: %w", err)
+	}
+	return bs, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Limits on display names, in characters
+const (
+	minNameLength = 2
+	maxNameLength = 24
+)
+
+var ErrInvalidName = errors.New("invalid name")
+var ErrNameTaken = errors.New("name is already taken")
+
+// Words a display name can't contain.
+//
+// This is deliberately short: it catches the obvious cases without flagging innocent names.
+var blockedWords = []string{
+	"asshole",
+	"bitch",
+	"cunt",
+	"fag",
+	"faggot",
+	"fuck",
+	"fucker",
+	"nazi",
+	"nigga",
+	"nigger",
+	"retard",
+	"shit",
+	"slut",
+	"whore",
+}
+
+// Common character swaps used to dodge word filters.
+var leetReplacer = strings.NewReplacer(
+	"0", "o",
+	"1", "i",
+	"3", "e",
+	"4", "a",
+	"5", "s",
+	"7", "t",
+	"@", "a",
+	"$", "s",
+	"!", "i",
+)
+
+// NormalizeName cleans up a requested display name, and checks that it's acceptable.
+//
+// Names are NFKC-normalized, so look-alike forms such as full-width letters
+// can't dodge the uniqueness and profanity checks. See cleanText for the rest of the clean up.
+func NormalizeName(name string) (string, error) {
+	name = cleanText(norm.NFKC.String(name))
+
+	length := utf8.RuneCountInString(name)
+	if length < minNameLength || length > maxNameLength {
+		return "", fmt.Errorf("%w: must be %d to %d characters", ErrInvalidName, minNameLength, maxNameLength)
+	}
+	if IsProfane(name) {
+		return "", fmt.Errorf("%w: please pick something else", ErrInvalidName)
+	}
+	return name, nil
+}
+
+// IsProfane checks if text contains a blocked word,
+// either as a word of its own or spelled out with separators, like "f.u.c.k".
+func IsProfane(text string) bool {
+	text = leetReplacer.Replace(strings.ToLower(norm.NFKC.String(text)))
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	joined := strings.Join(words, "")
+	for _, blocked := range blockedWords {
+		if joined == blocked || joined == blocked+"s" {
+			return true
+		}
+		for _, word := range words {
+			if word == blocked || word == blocked+"s" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SameName checks if two display names would be confused for each other.
+func SameName(a, b string) bool {
+	return strings.EqualFold(norm.NFKC.String(a), norm.NFKC.String(b))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{"plain", "Alice", "Alice", nil},
+		{"surrounding space", "  Alice  ", "Alice", nil},
+		{"inner whitespace", "Alice \t\n Bob", "Alice Bob", nil},
+		{"invisible characters", "Ali\u200bce", "Alice", nil},
+		{"full-width letters", "Ａｌｉｃｅ", "Alice", nil},
+		{"non-Latin", "Zoë 🦊", "Zoë 🦊", nil},
+		{"shortest", "Al", "Al", nil},
+		{"too short", "A", "", ErrInvalidName},
+		{"too short once cleaned", " A\u200b ", "", ErrInvalidName},
+		{"longest", strings.Repeat("a", maxNameLength), strings.Repeat("a", maxNameLength), nil},
+		{"too long", strings.Repeat("a", maxNameLength+1), "", ErrInvalidName},
+		{"profane", "shit", "", ErrInvalidName},
+		{"profane in full-width letters", "ｓｈｉｔ", "", ErrInvalidName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeName(tt.input)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("NormalizeName(%q) = %q, %v, want %q, %v", tt.input, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsProfane(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Alice", false},
+		{"shit", true},
+		{"SHIT", true},
+		{"shits", true},
+		{"holy shit", true},
+		{"sh1t", true},
+		{"$h!t", true},
+		{"s.h.i.t", true},
+		{"s h i t", true},
+		{"ｓｈｉｔ", true},
+		// Blocked words inside innocent ones are fine
+		{"Scunthorpe", false},
+		{"Cocktail", false},
+		{"Shitake", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := IsProfane(tt.text); got != tt.want {
+				t.Errorf("IsProfane(%q) = %t, want %t", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameName(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"Alice", "Alice", true},
+		{"Alice", "aLICE", true},
+		{"Alice", "Ａｌｉｃｅ", true},
+		{"Alice", "Alicia", false},
+		{"Alice", "Alice Bob", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := SameName(tt.a, tt.b); got != tt.want {
+				t.Errorf("SameName(%q, %q) = %t, want %t", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -5,12 +5,26 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// Maximum number of draw events kept for replay; see Room.Strokes.
+const maxStrokeLog = 50000
+
+// Maximum number of spectators per room, on top of its players.
+const maxSpectators = 20
+
+// How long a disconnected player's seat is held for them to resume; see Room.Resume.
+const resumeGracePeriod = 30 * time.Second
+
 var ErrRoomFull = errors.New("room is full")
+var ErrNoHeldSlot = errors.New("no seat held for connection")
+var ErrBanned = errors.New("banned from room")
 var ErrGameInProgress = errors.New("game is in progress")
+var ErrNoSuchPlayer = errors.New("no such player")
+var ErrSelfTarget = errors.New("you can't do that to yourself")
 
 type Role string
 
@@ -34,70 +48,597 @@ type Room struct {
 	ID     string
 	// Map to check membership of conn, as well as count of active players
 	Conns map[*Connection]bool
+	// ID of the room owner, who can start games and configure the room.
+	// Ownership passes to the longest-present player when the owner leaves.
+	Owner string
+	// Connection IDs and IP addresses banned by the owner, for the lifetime of the room
+	BannedIDs map[string]bool
+	BannedIPs map[string]bool
+	// Connection IDs and IP addresses muted by the owner; muted players can't chat.
+	// Like bans, these cover the IP so reconnecting without a resume token doesn't lift the mute.
+	Muted    map[string]bool
+	MutedIPs map[string]bool
+	// Map of connection ID -> display name chosen by the player, if any; see Room.SetName
+	Names map[string]string
+	// Map of connection ID -> color from Palette; every seated player has one. See Room.SetColor
+	Colors map[string]string
+	// Settings chosen when the room was created
+	Config RoomConfig
 	// Maximum number of players in room
 	Size int
 	// Ordered mapping of position to player
 	Slots []*Connection
+	// Connections watching the room without a seat, in order of arrival.
+	// Spectators see everything except roles and the prompt.
+	// This doubles as a FIFO queue for seats, which are handed out whenever one is free between games.
+	Spectators []*Connection
+	// Map of slot index -> seat held for a disconnected player; see Room.Resume.
+	// A held slot is nil in Slots, but can't be taken by anyone else.
+	Held map[int]*HeldSlot
 	// Game state machine
 	Game *Game
+	// Map of connection ID -> points scored in this room.
+	// Unlike Game, this persists across games until the Match ends.
+	Scores map[string]int
+	// Series of games the room is playing
+	Match *Match
+	// Ordered log of draw events on the canvas, replayed to clients when they join.
+	// Cleared when a game starts or the canvas is cleared.
+	Strokes []*DrawMessage
+
+	// Countdown for the current turn or game state; see resetDeadlineUnsafe.
+	deadline        *time.Timer
+	deadlineStarted time.Time
+	deadlineLimit   time.Duration
+	// Incremented whenever the countdown changes, so a stale timer can tell it's been replaced
+	deadlineID int
+
+	// Sequence number of the last event broadcast to the room; see MakeEvent
+	seq int64
+}
+
+// HeldSlot reserves a seat for a disconnected player until they resume or the grace period ends.
+type HeldSlot struct {
+	// ID of the disconnected connection
+	ID string
+	// When the disconnected connection first joined
+	Joined time.Time
+	timer  *time.Timer
 }
 
-func NewRoom(id string, size int) *Room {
-	//TODO validate room size, return error
-	slots := make([]*Connection, size)
+func NewRoom(id string, config RoomConfig) (*Room, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	slots := make([]*Connection, config.Size)
 	for i := range slots {
 		slots[i] = nil
 	}
 	return &Room{
-		ID:    id,
-		Conns: make(map[*Connection]bool),
-		Size:  size,
-		Slots: slots,
-		Game:  &Game{State: Waiting},
-	}
+		ID:        id,
+		Config:    config,
+		Conns:     make(map[*Connection]bool),
+		BannedIDs: make(map[string]bool),
+		BannedIPs: make(map[string]bool),
+		Muted:     make(map[string]bool),
+		MutedIPs:  make(map[string]bool),
+		Names:     make(map[string]string),
+		Colors:    make(map[string]string),
+		Size:      config.Size,
+		Slots:     slots,
+		Held:      make(map[int]*HeldSlot),
+		Game:      &Game{State: Waiting, Rounds: config.Rounds},
+		Scores:    make(map[string]int),
+		Match:     NewMatch(),
+	}, nil
 }
 
 func (r *Room) Add(conn *Connection) error {
 	r.mux.Lock()
 	defer r.mux.Unlock()
-	if !r.Game.IsJoinable() {
-		return ErrGameInProgress
+	if r.BannedIDs[conn.ID] || r.BannedIPs[conn.IP] {
+		return ErrBanned
 	}
-	if len(r.Conns) < r.Size {
-		// Find a slot for user
-		for i, slot := range r.Slots {
-			if slot == nil { // add user
-				r.Slots[i] = conn
-				conn.PlayerNumber = i + 1
-				break
-			} else if i == (r.Size - 1) { // no slots!
-				return fmt.Errorf("expected open slot in room %s but found none", r.ID)
+	// Don't jump the queue
+	if r.Game.IsJoinable() && len(r.Spectators) == 0 && len(r.Conns)+len(r.Held) < r.Size {
+		return r.seatUnsafe(conn)
+	}
+	// No seat for now, so wait in line
+	if len(r.Spectators) >= maxSpectators {
+		log.Printf("room %s full at %d/%d with %d spectators", r.ID, len(r.Conns), r.Size, len(r.Spectators))
+		return ErrRoomFull
+	}
+	conn.PlayerNumber = 0
+	r.Spectators = append(r.Spectators, conn)
+	return nil
+}
+
+// seatUnsafe gives conn the first open seat.
+// Not threadsafe.
+func (r *Room) seatUnsafe(conn *Connection) error {
+	// Find a slot for user
+	for i, slot := range r.Slots {
+		if slot == nil && r.Held[i] == nil { // add user
+			r.Slots[i] = conn
+			conn.PlayerNumber = i + 1
+			if !InPalette(r.Colors[conn.ID]) || r.colorTakenUnsafe(conn.ID, r.Colors[conn.ID]) {
+				r.Colors[conn.ID] = r.freeColorUnsafe()
 			}
+			break
+		} else if i == (r.Size - 1) { // no slots!
+			return fmt.Errorf("expected open slot in room %s but found none", r.ID)
 		}
-		r.Conns[conn] = true
-		return nil
-	} else {
-		// Feature: add user to queue, allow spectating
-		log.Printf("room %s full at %d/%d", r.ID, len(r.Conns), r.Size)
-		return ErrRoomFull
+	}
+	r.Conns[conn] = true
+	if r.Owner == "" {
+		r.Owner = conn.ID
+	}
+	return nil
+}
+
+// seatSpectatorsUnsafe moves spectators into any open seats, in order of arrival,
+// and tells them their new player number.
+// Seats are only handed out between games.
+// Not threadsafe.
+func (r *Room) seatSpectatorsUnsafe() {
+	if !r.Game.IsJoinable() {
+		return
+	}
+	seated := false
+	for len(r.Spectators) > 0 && len(r.Conns)+len(r.Held) < r.Size {
+		conn := r.Spectators[0]
+		r.Spectators = r.Spectators[1:]
+		if err := r.seatUnsafe(conn); err != nil {
+			log.Printf("error seating spectator: %s", err)
+			r.Spectators = append([]*Connection{conn}, r.Spectators...)
+			break
+		}
+		seated = true
+		bs, err := r.connectionMessageUnsafe(conn)
+		if err != nil {
+			log.Printf("error marshalling connection message: %s", err)
+		} else {
+			conn.Send(bs)
+		}
+		conn.Notify(fmt.Sprintf("A seat opened up! You are now Player #%d.", conn.PlayerNumber), false)
+	}
+	if seated {
+		r.broadcastConnectionsUnsafe()
+		r.publishScores()
+		r.publishQueueUnsafe()
 	}
 }
 
-// Delete conn from room, and return number of remaining connections.
+// publishQueueUnsafe tells each spectator their place in line for a seat.
+// Not threadsafe.
+func (r *Room) publishQueueUnsafe() {
+	for i, conn := range r.Spectators {
+		bs, err := MakeMessage[QueueMessage]("queue", QueueMessage{Position: i + 1})
+		if err != nil {
+			log.Printf("error marshalling queue message: %s", err)
+			return
+		}
+		conn.Send(bs)
+	}
+}
+
+// Delete conn from room, and return number of remaining connections, including held seats.
 //
 // This allows for an atomic check of the length after deletion
 // to confirm room is empty.
+//
+// The player's seat is held for resumeGracePeriod in case they reconnect.
+// If they don't, the seat is released, and the room deletes itself if nobody else is left.
 func (r *Room) Remove(conn *Connection) int {
 	r.mux.Lock()
 	defer r.mux.Unlock()
 	delete(r.Conns, conn)
 	// Remember: PlayerNumber is 1-indexed
-	if conn.PlayerNumber <= len(r.Slots) {
-		r.Slots[conn.PlayerNumber-1] = nil
+	if conn.IsSpectator() {
+		for i, c := range r.Spectators {
+			if c == conn {
+				r.Spectators = append(r.Spectators[:i], r.Spectators[i+1:]...)
+				break
+			}
+		}
+		// Everyone behind them moves up
+		r.publishQueueUnsafe()
+	} else if conn.PlayerNumber <= len(r.Slots) && r.Slots[conn.PlayerNumber-1] != conn {
+		// Replaced by a new connection for the same player; see Resume
+	} else if conn.PlayerNumber <= len(r.Slots) && conn.kicked {
+		// No second chances, so free the seat right away
+		i := conn.PlayerNumber - 1
+		r.Slots[i] = nil
+		r.removePlayerUnsafe(i)
+		r.seatSpectatorsUnsafe()
+	} else if conn.PlayerNumber <= len(r.Slots) {
+		i := conn.PlayerNumber - 1
+		r.Slots[i] = nil
+		held := &HeldSlot{ID: conn.ID, Joined: conn.Joined}
+		held.timer = time.AfterFunc(resumeGracePeriod, func() {
+			r.mux.Lock()
+			defer r.mux.Unlock()
+			if r.Held[i] != held {
+				// Already resumed
+				return
+			}
+			r.releaseSlotUnsafe(i)
+		})
+		r.Held[i] = held
+		r.notifyAllUnsafe(fmt.Sprintf("Player #%d disconnected. Holding their seat for %.0f seconds.",
+			conn.PlayerNumber, resumeGracePeriod.Seconds()), false)
 	} else {
 		log.Printf("Error: conn %s has player number %d in room of size %d", conn.ID, conn.PlayerNumber, r.Size)
 	}
-	return len(r.Conns)
+	return len(r.Conns) + len(r.Held) + len(r.Spectators)
+}
+
+// Resume seats conn in the slot held for the disconnected connection with the given ID.
+// If that connection hasn't been noticed to drop yet, it's closed and conn takes its slot instead.
+//
+// conn takes over the old connection's ID and player number, and with them its role and score.
+func (r *Room) Resume(conn *Connection, id string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.BannedIDs[id] || r.BannedIPs[conn.IP] {
+		return ErrBanned
+	}
+	for i, held := range r.Held {
+		if held.ID != id {
+			continue
+		}
+		held.timer.Stop()
+		delete(r.Held, i)
+		conn.ID = id
+		conn.PlayerNumber = i + 1
+		conn.Joined = held.Joined
+		r.Slots[i] = conn
+		r.Conns[conn] = true
+		if r.Owner == "" {
+			r.Owner = conn.ID
+		}
+		r.notifyAllUnsafe(fmt.Sprintf("Player #%d reconnected.", conn.PlayerNumber), false)
+		return nil
+	}
+	// The old connection may still look alive, since a dead one isn't noticed until it misses a heartbeat.
+	// The token proves this is the same player, so take over their seat.
+	for i, old := range r.Slots {
+		if old == nil || old.ID != id {
+			continue
+		}
+		delete(r.Conns, old)
+		conn.ID = id
+		conn.PlayerNumber = i + 1
+		conn.Joined = old.Joined
+		r.Slots[i] = conn
+		r.Conns[conn] = true
+		// Remove ignores old once it's out of its slot
+		old.CloseWithReason(websocket.CloseNormalClosure, "You reconnected from somewhere else.")
+		r.notifyAllUnsafe(fmt.Sprintf("Player #%d reconnected.", conn.PlayerNumber), false)
+		return nil
+	}
+	return ErrNoHeldSlot
+}
+
+// releaseSlotUnsafe gives up a held seat once its grace period has ended.
+// Not threadsafe.
+func (r *Room) releaseSlotUnsafe(i int) {
+	id := r.Held[i].ID
+	delete(r.Held, i)
+	if len(r.Conns) == 0 && len(r.Held) == 0 && len(r.Spectators) == 0 {
+		r.stopDeadlineUnsafe()
+		if r.Server != nil {
+			r.Server.DeleteRoom(r)
+		}
+		return
+	}
+	r.notifyAllUnsafe(fmt.Sprintf("Player #%d left.", i+1), false)
+	if id == r.Owner {
+		r.reassignOwnerUnsafe()
+	}
+	r.broadcastConnectionsUnsafe()
+	r.removePlayerUnsafe(i)
+	r.seatSpectatorsUnsafe()
+}
+
+// removePlayerUnsafe drops a departed player from the game, and lets everyone know what that means for the game.
+// Not threadsafe.
+func (r *Room) removePlayerUnsafe(player int) {
+	switch r.Game.RemovePlayer(player) {
+	case DepartureIgnored, DepartureRemoved:
+		return
+	case DepartureMuseReassigned:
+		r.Match.LastMuse = r.Game.Muse
+		r.notifyAllUnsafe("The Muse left, so someone else will pick the prompt.", false)
+		// The new Muse gets a fresh countdown
+		r.resetDeadlineUnsafe()
+		r.broadcastStateUnsafe()
+		if muse := r.Slots[r.Game.Muse]; muse != nil {
+			muse.SendRole(Muse)
+			muse.Notify("You are the new Muse! Pick a prompt for the round.", false)
+		}
+	case DepartureTurnSkipped:
+		r.notifyAllUnsafe(fmt.Sprintf("Player #%d left during their turn.", player+1), false)
+		r.resetDeadlineUnsafe()
+		r.broadcastStateUnsafe()
+		r.announceTurnUnsafe()
+	case DepartureVotingClosed:
+		r.afterTallyUnsafe()
+	case DepartureGuessRejected:
+		r.notifyAllUnsafe("The Muse left before judging, so the Poser's guess doesn't count.", false)
+		r.resetDeadlineUnsafe()
+		r.broadcastStateUnsafe()
+		r.finishGameUnsafe()
+	case DeparturePoserLeft:
+		r.abortGameUnsafe(fmt.Sprintf("Player #%d was the Poser, and left. Game over!", player+1))
+	case DepartureNotEnoughPlayers:
+		r.abortGameUnsafe("Not enough players left to continue. Game over!")
+	}
+}
+
+// TransferOwner hands ownership of the room from owner to the player with the given number.
+func (r *Room) TransferOwner(owner *Connection, playerNumber int) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if owner.ID != r.Owner {
+		return ErrNotHost
+	}
+	if playerNumber < 1 || playerNumber > len(r.Slots) || r.Slots[playerNumber-1] == nil {
+		return fmt.Errorf("%w: no player #%d in room", ErrNoSuchPlayer, playerNumber)
+	}
+	r.Owner = r.Slots[playerNumber-1].ID
+	r.notifyAllUnsafe(fmt.Sprintf("Player #%d is now the host.", playerNumber), false)
+	r.broadcastConnectionsUnsafe()
+	return nil
+}
+
+// Kick closes the connection of the player with the given number, or of the connection with ID id if set,
+// freeing their seat.
+// If ban is set, their connection ID and IP address can't rejoin for the lifetime of the room.
+func (r *Room) Kick(owner *Connection, playerNumber int, id string, ban bool) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	target, err := r.moderationTargetUnsafe(owner, playerNumber, id)
+	if err != nil {
+		return err
+	}
+	target.kicked = true
+	action := "kicked"
+	if ban {
+		action = "banned"
+		r.BannedIDs[target.ID] = true
+		r.BannedIPs[target.IP] = true
+	}
+	log.Printf("%s: %s (%s) %s by host", r.ID, target.ID, target.IP, action)
+	// This ends target's read loop, which removes it from the room.
+	target.CloseWithReason(websocket.ClosePolicyViolation, fmt.Sprintf("You were %s by the host.", action))
+	// target is already closing, so it won't get this
+	r.notifyAllUnsafe(fmt.Sprintf("%s was %s by the host.", describeTarget(target), action), false)
+	return nil
+}
+
+// Mute stops (or, if muted is false, allows) the player with the given number from chatting.
+// As with Kick, id picks the target instead if set.
+func (r *Room) Mute(owner *Connection, playerNumber int, id string, muted bool) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	target, err := r.moderationTargetUnsafe(owner, playerNumber, id)
+	if err != nil {
+		return err
+	}
+	action := "unmuted"
+	if muted {
+		action = "muted"
+		r.Muted[target.ID] = true
+		r.MutedIPs[target.IP] = true
+	} else {
+		delete(r.Muted, target.ID)
+		delete(r.MutedIPs, target.IP)
+	}
+	r.notifyAllUnsafe(fmt.Sprintf("%s was %s by the host.", describeTarget(target), action), false)
+	return nil
+}
+
+// IsMuted checks if conn has been muted by the owner.
+func (r *Room) IsMuted(conn *Connection) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.Muted[conn.ID] || r.MutedIPs[conn.IP]
+}
+
+// SetName validates and sets the display name of conn.
+//
+// Names must be unique within the room, ignoring case.
+func (r *Room) SetName(conn *Connection, name string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	name, err := NormalizeName(name)
+	if err != nil {
+		return err
+	}
+	if r.nameTakenUnsafe(conn.ID, name) {
+		return ErrNameTaken
+	}
+	r.Names[conn.ID] = name
+	r.broadcastConnectionsUnsafe()
+	return nil
+}
+
+// LeaksPrompt checks if a chat message from conn would give away the prompt.
+//
+// Only players who know the prompt are checked, and only while the Poser is still trying to work it out.
+// Checking anyone else would tip them off about what the prompt is.
+func (r *Room) LeaksPrompt(conn *Connection, text string) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.Game.State != Drawing && r.Game.State != Voting {
+		return false
+	}
+	if conn.IsSpectator() || conn.PlayerNumber-1 == r.Game.Poser {
+		return false
+	}
+	return containsPhrase(text, r.Game.Prompt)
+}
+
+// Name gets the display name of conn, or the empty string if it hasn't picked one.
+func (r *Room) Name(conn *Connection) string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.Names[conn.ID]
+}
+
+// Checks if anyone in the room other than id is using name, including players holding a seat.
+//
+// Not threadsafe.
+func (r *Room) nameTakenUnsafe(id string, name string) bool {
+	ids := make([]string, 0, len(r.Slots)+len(r.Spectators))
+	for i := range r.Slots {
+		ids = append(ids, r.slotIDUnsafe(i))
+	}
+	for _, conn := range r.Spectators {
+		ids = append(ids, conn.ID)
+	}
+	for _, other := range ids {
+		if other != "" && other != id && SameName(r.Names[other], name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetColor switches the drawing color of conn to another unused color from Palette.
+//
+// Colors can only be changed between games, so nobody's strokes change color mid-game.
+func (r *Room) SetColor(conn *Connection, color string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if conn.IsSpectator() {
+		return errors.New("spectators don't have a color")
+	}
+	if r.Game.State != Waiting {
+		return ErrGameInProgress
+	}
+	if !InPalette(color) {
+		return ErrInvalidColor
+	}
+	if r.colorTakenUnsafe(conn.ID, color) {
+		return ErrColorTaken
+	}
+	r.Colors[conn.ID] = color
+	r.broadcastConnectionsUnsafe()
+	return nil
+}
+
+// Checks if any seated player other than id is using color, including players holding a seat.
+//
+// Not threadsafe.
+func (r *Room) colorTakenUnsafe(id string, color string) bool {
+	for i := range r.Slots {
+		if other := r.slotIDUnsafe(i); other != "" && other != id && r.Colors[other] == color {
+			return true
+		}
+	}
+	return false
+}
+
+// Finds the first color in Palette that no seated player is using.
+//
+// Not threadsafe.
+func (r *Room) freeColorUnsafe() string {
+	for _, color := range Palette {
+		if !r.colorTakenUnsafe("", color) {
+			return color
+		}
+	}
+	// Palette has more colors than a room has seats, so this shouldn't happen
+	log.Printf("%s: ran out of colors", r.ID)
+	return Palette[0]
+}
+
+// moderationTargetUnsafe finds the connection for the player with the given number,
+// or for the player or spectator with ID id if it's set,
+// checking that owner is allowed to moderate them.
+// Not threadsafe.
+func (r *Room) moderationTargetUnsafe(owner *Connection, playerNumber int, id string) (*Connection, error) {
+	if owner.ID != r.Owner {
+		return nil, ErrNotHost
+	}
+	var target *Connection
+	if id != "" {
+		for _, conn := range r.Slots {
+			if conn != nil && conn.ID == id {
+				target = conn
+			}
+		}
+		for _, conn := range r.Spectators {
+			if conn.ID == id {
+				target = conn
+			}
+		}
+		if target == nil {
+			return nil, fmt.Errorf("%w: nobody in room with that ID", ErrNoSuchPlayer)
+		}
+	} else {
+		if playerNumber < 1 || playerNumber > len(r.Slots) || r.Slots[playerNumber-1] == nil {
+			return nil, fmt.Errorf("%w: no player #%d in room", ErrNoSuchPlayer, playerNumber)
+		}
+		target = r.Slots[playerNumber-1]
+	}
+	if target == owner {
+		return nil, ErrSelfTarget
+	}
+	return target, nil
+}
+
+// describeTarget names a moderated connection in notifications.
+func describeTarget(conn *Connection) string {
+	if conn.IsSpectator() {
+		return "A spectator"
+	}
+	return fmt.Sprintf("Player #%d", conn.PlayerNumber)
+}
+
+// reassignOwnerUnsafe passes ownership to the connected player who has been in the room longest.
+// If nobody is connected, the room has no owner until someone joins or resumes.
+// Not threadsafe.
+func (r *Room) reassignOwnerUnsafe() {
+	var owner *Connection
+	for conn := range r.Conns {
+		if owner == nil || conn.Joined.Before(owner.Joined) {
+			owner = conn
+		}
+	}
+	if owner == nil {
+		r.Owner = ""
+		return
+	}
+	r.Owner = owner.ID
+	r.notifyAllUnsafe(fmt.Sprintf("The host left. Player #%d is now the host.", owner.PlayerNumber), false)
+}
+
+// Summary describes the room for public listings.
+func (r *Room) Summary() RoomSummary {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return RoomSummary{
+		ID:      r.ID,
+		Players: len(r.Conns),
+		Size:    r.Size,
+		State:   r.Game.State,
+	}
+}
+
+// IsEmpty checks if the room has no connections, held seats, or spectators.
+func (r *Room) IsEmpty() bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return len(r.Conns) == 0 && len(r.Held) == 0 && len(r.Spectators) == 0
 }
 
 func (r *Room) String() string {
@@ -106,43 +647,63 @@ func (r *Room) String() string {
 
 /* Room communication methods */
 
-func (r *Room) Broadcast(from *Connection, message []byte) {
+// Chat stamps a chat message with the current time, then broadcasts it to everyone in the room.
+func (r *Room) Chat(m *ChatMessage) error {
 	r.mux.Lock()
 	defer r.mux.Unlock()
-	r.broadcastUnsafe(from, message)
-}
 
-// BroadcastType broadcasts a message of type T to all connections in the room.
-// If from is non-nil, that connection will be omitted.
-// If the message is successfully marshalled to JSON, it will be sent in a separate goroutine.
-//
-// Note that this can't be a method since it's a generic function.
-func BroadcastType[T any](room *Room, from *Connection, messageType string, message T) error {
-	bs, err := MakeMessage[T](messageType, message)
+	m.Timestamp = time.Now().UnixMilli()
+	bs, err := MakeEvent(r.nextSeqUnsafe(), "chat", m)
 	if err != nil {
 		return err
 	}
-	go room.Broadcast(from, bs)
+	r.broadcastUnsafe(nil, bs)
 	return nil
 }
 
+// Not threadsafe.
+func (r *Room) nextSeqUnsafe() int64 {
+	r.seq++
+	return r.seq
+}
+
 // BroadcastConnections informs all clients in the room of the current list of players.
 func (r *Room) BroadcastConnections() {
 	r.mux.Lock()
 	defer r.mux.Unlock()
+	r.broadcastConnectionsUnsafe()
+}
 
+// Not threadsafe.
+func (r *Room) broadcastConnectionsUnsafe() {
 	// Build list of IDs
 	ids := make([]string, 0)
+	names := make([]string, 0)
+	colors := make([]string, 0)
+	owner := 0
 	// Get these from slots in order to maintain player order
-	for _, conn := range r.Slots {
+	for i, conn := range r.Slots {
 		if conn == nil {
 			ids = append(ids, "")
 		} else {
 			ids = append(ids, conn.ID)
 		}
+		// Held seats keep their name, so it shows up again when the player resumes
+		names = append(names, r.Names[r.slotIDUnsafe(i)])
+		colors = append(colors, r.Colors[r.slotIDUnsafe(i)])
+		// The owner may be disconnected, but still holding their seat
+		if id := r.slotIDUnsafe(i); id != "" && id == r.Owner {
+			owner = i + 1
+		}
 	}
 	// Create JSON
-	bs, err := MakeMessage[PlayersMessage]("players", PlayersMessage{IDs: ids})
+	bs, err := MakeMessage[PlayersMessage]("players", PlayersMessage{
+		IDs:        ids,
+		Names:      names,
+		Colors:     colors,
+		Owner:      owner,
+		Spectators: len(r.Spectators),
+	})
 	if err != nil {
 		log.Printf("Error marshalling connections: %s", err)
 		return
@@ -151,38 +712,59 @@ func (r *Room) BroadcastConnections() {
 	r.broadcastUnsafe(nil, bs)
 }
 
+// BroadcastScores informs all clients in the room of the current scoreboard.
+func (r *Room) BroadcastScores() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.publishScores()
+}
+
 // Non-threadsafe broadcast; callers must handle locking.
+// Spectators receive broadcasts too, so never broadcast anything secret, like roles or the prompt.
 func (r *Room) broadcastUnsafe(from *Connection, message []byte) {
 	for conn := range r.Conns {
 		if conn != nil && conn != from {
-			conn.WriteMessage(websocket.TextMessage, message)
+			conn.Send(message)
+		}
+	}
+	for _, conn := range r.Spectators {
+		if conn != from {
+			conn.Send(message)
 		}
 	}
 }
 
 /* Game state methods */
 
-func (r *Room) Start() {
+func (r *Room) Start() error {
 	r.mux.Lock()
 	defer r.mux.Unlock()
 
 	players := r.getActivePlayerNumbers()
 
-	err := r.Game.Start(players)
-	if err == ErrGameInProgress {
-		// Here, this could mean the client just clicked a few times, so we can disregard.
-		log.Println("error: Start() issued for in-progress game")
-		return
+	nextMuse := -1
+	if len(players) > 0 {
+		nextMuse = r.Match.NextMuse(players)
+	}
+	err := r.Game.StartWithMuse(players, nextMuse)
+	if err == ErrGameInProgress || err == ErrNotEnoughPlayers {
+		// Nothing has changed, so just let the host know
+		return err
 	} else if err != nil {
 		log.Printf("error starting game: %s", err)
-		r.abortGameUnsafe("Couldn't start game. Not enough players.")
-		return
+		r.abortGameUnsafe("Whoops! There was an error starting the game.")
+		return err
 	}
 
+	r.Match.LastMuse = r.Game.Muse
+	// Clients clear their own canvas when the game starts, so just drop the log.
+	r.Strokes = nil
+
 	log.Printf("Starting game for room %s", r.ID)
 	// Notify all, but don't reveal the Muse to other players here!
 	// Doing so reduces the number of possible fake artists, which is less fun in small games.
 	r.notifyAllUnsafe("Game starting! The Muse is contemplating...", false)
+	r.resetDeadlineUnsafe()
 	r.broadcastStateUnsafe()
 
 	// Notify Muse
@@ -193,62 +775,398 @@ func (r *Room) Start() {
 	if err != nil {
 		log.Printf("error marshalling role message: %s", err)
 		r.abortGameUnsafe("Whoops! There was an error starting the game.")
-		return
+		return err
 	}
-	err = muse.WriteMessage(websocket.TextMessage, bs)
+	err = muse.Send(bs)
 	if err != nil {
 		log.Printf("error sending role message: %s", err)
 		r.abortGameUnsafe("Whoops! There was an error starting the game.")
+		return err
+	}
+	// Everyone else is an Artist until the Poser is revealed to themselves.
+	// This also resets any role left over from a previous game.
+	for conn := range r.Conns {
+		if conn != muse {
+			conn.SendRole(Artist)
+		}
+	}
+	return nil
+}
+
+// Draw shares part of a stroke from conn with the rest of the room.
+//
+// Anyone can doodle while Waiting, but during a game only the current player can draw,
+// and only a single stroke. Ending that stroke ends their turn.
+//
+// Rejected segments and ends of strokes are dropped without an error,
+// so the sender hears about a rejected stroke once rather than for every segment.
+func (r *Room) Draw(conn *Connection, m *DrawMessage) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	err := r.drawUnsafe(conn, m)
+	if err != nil && (m.Stroke == StrokeSegment || m.Stroke == StrokeEnd) {
+		return nil
+	}
+	return err
+}
+
+// Not threadsafe.
+func (r *Room) drawUnsafe(conn *Connection, m *DrawMessage) error {
+	if conn.IsSpectator() {
+		return ErrSpectating
+	}
+	// Set source player, ignore anything client may have set.
+	m.PlayerNumber = conn.PlayerNumber
+	m.Color = r.Colors[conn.ID]
+
+	endsTurn := false
+	switch r.Game.State {
+	case Waiting:
+		if m.Stroke != StrokeStart && m.Stroke != StrokeSegment && m.Stroke != StrokeEnd {
+			return fmt.Errorf("%w: unknown phase %q", ErrInvalidStroke, m.Stroke)
+		}
+	case Drawing:
+		var err error
+		endsTurn, err = r.Game.Draw(conn.PlayerNumber-1, m.Stroke)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: can't do that while %s", ErrInvalidState, r.Game.State)
+	}
+
+	bs, err := MakeEvent[*DrawMessage](r.nextSeqUnsafe(), "draw", m)
+	if err != nil {
+		return err
+	}
+	if len(r.Strokes) < maxStrokeLog {
+		r.Strokes = append(r.Strokes, m)
+		if len(r.Strokes) == maxStrokeLog {
+			// Keep drawing, but late joiners will be missing the rest.
+			log.Printf("%s: stroke log is full, no longer recording draw events", r.ID)
+		}
+	}
+	// The sender already drew this locally, but gets it anyway so its sequence numbers don't skip
+	r.broadcastUnsafe(nil, bs)
+
+	if endsTurn {
+		r.endTurnUnsafe(conn.PlayerNumber - 1)
+	}
+	return nil
+}
+
+// Clear wipes the canvas for everyone in the room.
+//
+// This is only allowed between games, since the drawing is the whole point of a game.
+func (r *Room) Clear() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if !r.Game.IsJoinable() {
+		return ErrGameInProgress
+	}
+	r.clearCanvasUnsafe()
+	return nil
+}
+
+// SendGameState catches conn up on a game in progress, e.g. after resuming or when spectating.
+//
+// For players, this includes their role and, unless they're the Poser, the prompt.
+func (r *Room) SendGameState(conn *Connection) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	g := r.Game
+	started, duration := r.countdownUnsafe()
+	// Catch-up messages carry the latest sequence number, so conn knows where events pick up from
+	bs, err := MakeEvent(r.seq, "state", &StateMessage{State: g.State, Started: started, Duration: duration})
+	if err != nil {
+		log.Printf("error marshalling state message: %s", err)
+		return
+	}
+	conn.Send(bs)
+
+	if g.State == Drawing {
+		bs, err := MakeEvent[TurnMessage](r.seq, "turn", TurnMessage{
+			PlayerNumber: g.Drawing + 1,
+			Started:      started,
+			Duration:     duration,
+		})
+		if err != nil {
+			log.Printf("error marshalling turn message: %s", err)
+			return
+		}
+		conn.Send(bs)
+	}
+	if g.State == ValidatingGuess {
+		bs, err := MakeMessage[GuessMessage]("guess", GuessMessage{Guess: g.Guess})
+		if err != nil {
+			log.Printf("error marshalling guess message: %s", err)
+			return
+		}
+		conn.Send(bs)
+	}
+
+	player := conn.PlayerNumber - 1
+	if _, ok := g.PlayerStates[player]; g.State == Waiting || !ok {
+		// Not part of a game, e.g. a spectator
+		return
+	}
+
+	// The Poser only learns their role once the prompt is set
+	promptSet := g.State != GettingPrompt
+	switch {
+	case player == g.Muse:
+		conn.SendRole(Muse)
+	case player == g.Poser && promptSet:
+		conn.SendRole(Poser)
+	default:
+		conn.SendRole(Artist)
+	}
+	if promptSet && player != g.Poser {
+		conn.Notify(fmt.Sprintf("The prompt is: %s", g.Prompt), false)
+	}
+}
+
+// SendQueuePosition tells conn its place in line for a seat, if it's waiting for one.
+func (r *Room) SendQueuePosition(conn *Connection) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	for i, c := range r.Spectators {
+		if c != conn {
+			continue
+		}
+		bs, err := MakeMessage[QueueMessage]("queue", QueueMessage{Position: i + 1})
+		if err != nil {
+			log.Printf("error marshalling queue message: %s", err)
+			return
+		}
+		conn.Send(bs)
 		return
 	}
 }
 
-func (r *Room) SetPrompt(prompt string) {
+// SendConnection sends conn its connection message; see connectionMessageUnsafe.
+func (r *Room) SendConnection(conn *Connection) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	bs, err := r.connectionMessageUnsafe(conn)
+	if err != nil {
+		return err
+	}
+	return conn.Send(bs)
+}
+
+// SendStrokes replays the canvas to conn.
+func (r *Room) SendStrokes(conn *Connection) {
 	r.mux.Lock()
 	defer r.mux.Unlock()
 
-	err := r.Game.SetPrompt(prompt)
+	bs, err := MakeMessage[StrokesMessage]("strokes", StrokesMessage{Strokes: r.Strokes})
 	if err != nil {
-		log.Printf("error setting prompt: %s", err)
-		r.abortGameUnsafe(fmt.Sprintf("Couldn't set prompt: %s", err))
+		log.Printf("error marshalling strokes message: %s", err)
 		return
 	}
+	conn.Send(bs)
+}
+
+// ConfigureMatch sets the target score and number of games for the room's match.
+func (r *Room) ConfigureMatch(targetScore, targetGames int) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if !r.Game.IsJoinable() {
+		return ErrGameInProgress
+	}
+	if err := r.Match.Configure(targetScore, targetGames); err != nil {
+		return err
+	}
+	r.publishMatch()
+
+	var goal string
+	switch {
+	case targetScore > 0 && targetGames > 0:
+		goal = fmt.Sprintf("first to %d points, or best after %d games", targetScore, targetGames)
+	case targetScore > 0:
+		goal = fmt.Sprintf("first to %d points", targetScore)
+	case targetGames > 0:
+		goal = fmt.Sprintf("best after %d games", targetGames)
+	default:
+		goal = "no limit"
+	}
+	r.notifyAllUnsafe(fmt.Sprintf("Match rules set: %s.", goal), false)
+	return nil
+}
+
+// SendMatch sends the current match configuration and progress to conn.
+func (r *Room) SendMatch(conn *Connection) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	bs, err := r.matchMessageUnsafe()
+	if err != nil {
+		log.Printf("error marshalling match message: %s", err)
+		return
+	}
+	conn.Send(bs)
+}
+
+// SetPrompt sets the prompt for the current game and starts the drawing.
+//
+// A prompt that arrives too late, e.g. after the Muse ran out of time, is rejected with ErrInvalidState.
+func (r *Room) SetPrompt(prompt string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.setPromptUnsafe(prompt)
+}
+
+// Not threadsafe.
+func (r *Room) setPromptUnsafe(prompt string) error {
+	if err := r.Game.SetPrompt(prompt); err != nil {
+		return err
+	}
+	r.resetDeadlineUnsafe()
 	r.broadcastStateUnsafe()
 
-	// Notify everyone but Poser of prompt
+	// Notify everyone but Poser of prompt.
+	// The Poser may have disconnected, in which case they're told on resuming.
 	poser := r.Slots[r.Game.Poser]
-	poser.Notify(
-		"You are the poser! Just act cool, play along, and try to guess what you're drawing.",
-		false,
-	)
+	if poser != nil {
+		poser.SendRole(Poser)
+		poser.Notify(
+			"You are the poser! Just act cool, play along, and try to guess what you're drawing.",
+			false,
+		)
+	}
 	for c := range r.Conns {
 		if c != poser {
-			c.Notify(fmt.Sprintf("The prompt is: %s", prompt), false)
+			c.Notify(fmt.Sprintf("The prompt is: %s", r.Game.Prompt), false)
 		}
 	}
 	r.publishPlayerTurn(r.Game.Drawing + 1)
+	return nil
 }
 
-func (r *Room) EndTurn(player int) {
+// EndTurn ends the turn of player, if they're drawing.
+//
+// The turn may have already timed out while this request was in flight, so this checks again under the lock.
+func (r *Room) EndTurn(player int) error {
 	r.mux.Lock()
 	defer r.mux.Unlock()
+	if r.Game.State != Drawing {
+		return fmt.Errorf("%w: can't do that while %s", ErrInvalidState, r.Game.State)
+	}
+	if r.Game.Drawing != player {
+		return ErrNotYourTurn
+	}
+	r.endTurnUnsafe(player)
+	return nil
+}
 
+// Not threadsafe.
+func (r *Room) endTurnUnsafe(player int) {
 	err := r.Game.EndTurn(player)
 	if err != nil {
 		log.Printf("error ending turn: %s", err)
 		r.abortGameUnsafe(fmt.Sprintf("Couldn't end turn: %s", err))
 		return
 	}
+	r.resetDeadlineUnsafe()
 	r.broadcastStateUnsafe()
+	r.announceTurnUnsafe()
+}
+
+// announceTurnUnsafe tells everyone who's drawing next, or that it's time to vote.
+// Not threadsafe.
+func (r *Room) announceTurnUnsafe() {
 	if r.Game.State == Drawing {
 		r.publishPlayerTurn(r.Game.Drawing + 1)
 	} else if r.Game.State == Voting {
-		//TODO prompt players to vote
-		r.notifyAllUnsafe("Voting time! Vote for your favorite drawing.", false)
-		//TODO remove this
-		r.notifyAllUnsafe("(Voting is not yet implemented!)", true)
+		r.notifyAllUnsafe("Voting time! Vote for the player you think is the Poser.", false)
+	}
+}
+
+// CastVote records the vote of voter against target, tallying the votes once everyone has voted.
+//
+// A bad ballot doesn't end the game: the error is only for the voter.
+func (r *Room) CastVote(voter, target int) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if err := r.Game.CastVote(voter, target); err != nil {
+		return err
+	}
+	r.notifyAllUnsafe(fmt.Sprintf("Player #%d has voted.", voter+1), false)
+
+	if r.Game.Tally == nil {
+		// Still waiting on other voters
+		return nil
+	}
+	r.afterTallyUnsafe()
+	return nil
+}
+
+// afterTallyUnsafe announces the result of the vote, then either ends the game
+// or moves on to the Poser's guess.
+// Not threadsafe.
+func (r *Room) afterTallyUnsafe() {
+	r.publishTally()
+	r.resetDeadlineUnsafe()
+	r.broadcastStateUnsafe()
+	if r.Game.State != PoserGuessing {
+		r.finishGameUnsafe()
 		return
 	}
+	r.notifyAllUnsafe("You caught the Poser! They get one chance to guess the prompt.", false)
+	if poser := r.Slots[r.Game.Poser]; poser != nil {
+		poser.Notify("You've been caught! Guess the prompt to steal the win.", false)
+	}
+}
+
+// SubmitGuess records the Poser's guess at the prompt, then ends the game or asks the Muse to judge it.
+func (r *Room) SubmitGuess(player int, guess string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if err := r.Game.SubmitGuess(player, guess); err != nil {
+		return err
+	}
+
+	bs, err := MakeMessage[GuessMessage]("guess", GuessMessage{Guess: r.Game.Guess})
+	if err != nil {
+		log.Printf("error marshalling guess message: %s", err)
+	} else {
+		r.broadcastUnsafe(nil, bs)
+	}
+	r.resetDeadlineUnsafe()
+	r.broadcastStateUnsafe()
+	if r.Game.State != ValidatingGuess {
+		// Guess matched the prompt exactly, or the Muse has left and can't judge it
+		r.finishGameUnsafe()
+		return nil
+	}
+	r.notifyAllUnsafe(fmt.Sprintf("The Poser guessed \"%s\". The Muse will decide if that's correct.", r.Game.Guess), false)
+	if muse := r.Slots[r.Game.Muse]; muse != nil {
+		muse.Notify(fmt.Sprintf("Your prompt was \"%s\". Is the Poser's guess close enough?", r.Game.Prompt), false)
+	}
+	return nil
+}
+
+// JudgeGuess lets the Muse decide if the Poser's guess is close enough to the prompt, ending the game.
+func (r *Room) JudgeGuess(player int, correct bool) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if err := r.Game.JudgeGuess(player, correct); err != nil {
+		return err
+	}
+	r.resetDeadlineUnsafe()
+	r.broadcastStateUnsafe()
+	r.finishGameUnsafe()
+	return nil
 }
 
 // getActivePlayerNumbers returns a slice of player numbers.
@@ -270,22 +1188,44 @@ func (r *Room) getActivePlayerNumbers() []int {
 // Not threadsafe.
 func (r *Room) abortGameUnsafe(message string) {
 	r.Game.Abort()
+	r.stopDeadlineUnsafe()
 	r.notifyAllUnsafe(message, true)
 	r.broadcastStateUnsafe()
+	r.seatSpectatorsUnsafe()
+}
+
+// clearCanvasUnsafe drops the stroke log and tells all clients to clear their canvas.
+// Not threadsafe.
+func (r *Room) clearCanvasUnsafe() {
+	r.Strokes = nil
+	bs, err := MakeMessage[any]("clear", nil)
+	if err != nil {
+		log.Printf("error marshalling clear message: %s", err)
+		return
+	}
+	r.broadcastUnsafe(nil, bs)
 }
 
-// notifyAllUnsafe sends a Notification to all clients.
+// notifyAllUnsafe sends a Notification to all clients, including spectators.
 // Not threadsafe.
 func (r *Room) notifyAllUnsafe(message string, isErr bool) {
 	for conn := range r.Conns {
 		conn.Notify(message, isErr)
 	}
+	for _, conn := range r.Spectators {
+		conn.Notify(message, isErr)
+	}
 }
 
 // broadcastStateUnsafe sends current game state to all clients.
 // Not threadsafe.
 func (r *Room) broadcastStateUnsafe() {
-	bs, err := MakeMessage("state", &StateMessage{State: r.Game.State})
+	started, duration := r.countdownUnsafe()
+	bs, err := MakeEvent(r.nextSeqUnsafe(), "state", &StateMessage{
+		State:    r.Game.State,
+		Started:  started,
+		Duration: duration,
+	})
 	if err != nil {
 		log.Printf("failed to create StateMessage for broadcast")
 		return
@@ -299,14 +1239,222 @@ func (r *Room) broadcastStateUnsafe() {
 // This could probably be replaced in favor of a dedicated UI element for showing current turn.
 func (r *Room) publishPlayerTurn(playerNumber int) {
 	// Publish turn
-	bs, err := MakeMessage[TurnMessage]("turn", TurnMessage{PlayerNumber: playerNumber})
+	started, duration := r.countdownUnsafe()
+	bs, err := MakeEvent[TurnMessage](r.nextSeqUnsafe(), "turn", TurnMessage{
+		PlayerNumber: playerNumber,
+		Started:      started,
+		Duration:     duration,
+	})
 	if err != nil {
 		log.Printf("error marshalling turn message: %s", err)
 		r.abortGameUnsafe("Whoops! There was an error starting the game.")
 		return
 	}
-	for c := range r.Conns {
-		c.WriteMessage(websocket.TextMessage, bs)
-	}
+	r.broadcastUnsafe(nil, bs)
 	r.notifyAllUnsafe(fmt.Sprintf("It's Player #%d's turn to draw!", playerNumber), false)
 }
+
+// publishTally sends the result of the vote to all clients.
+//
+// Game uses player indices, so these are converted to player numbers for the client.
+// Not threadsafe.
+func (r *Room) publishTally() {
+	tally := r.Game.Tally
+	m := TallyMessage{
+		Accused: tally.Accused + 1,
+		Votes:   make(map[int]int),
+		Tied:    make([]int, 0, len(tally.Tied)),
+	}
+	for p, count := range tally.Counts {
+		m.Votes[p+1] = count
+	}
+	for _, p := range tally.Tied {
+		m.Tied = append(m.Tied, p+1)
+	}
+	bs, err := MakeMessage[TallyMessage]("tally", m)
+	if err != nil {
+		log.Printf("error marshalling tally message: %s", err)
+		return
+	}
+	r.broadcastUnsafe(nil, bs)
+
+	if tally.Accused < 0 {
+		r.notifyAllUnsafe("The vote was tied! Nobody was accused.", false)
+	} else {
+		r.notifyAllUnsafe(fmt.Sprintf("The players accused Player #%d!", m.Accused), false)
+	}
+}
+
+// finishGameUnsafe announces the outcome of a finished game and updates the scoreboard.
+// Not threadsafe.
+func (r *Room) finishGameUnsafe() {
+	r.publishOutcome()
+	for p, points := range r.Game.Points() {
+		if id := r.slotIDUnsafe(p); id != "" {
+			r.Scores[id] += points
+		}
+	}
+	r.Match.GamesPlayed++
+	r.publishScores()
+	r.endMatchIfOverUnsafe()
+	r.seatSpectatorsUnsafe()
+}
+
+// endMatchIfOverUnsafe declares the winner if the match is over,
+// then resets the scoreboard for a new match.
+// Otherwise, it just reports the match's progress.
+// Not threadsafe.
+func (r *Room) endMatchIfOverUnsafe() {
+	top := 0
+	winners := make([]int, 0)
+	for i, conn := range r.Slots {
+		if conn == nil {
+			continue
+		}
+		score := r.Scores[conn.ID]
+		if score > top {
+			top = score
+			winners = winners[:0]
+		}
+		if score == top {
+			winners = append(winners, i+1)
+		}
+	}
+	if !r.Match.IsOver(top) {
+		r.publishMatch()
+		return
+	}
+
+	bs, err := MakeMessage[MatchOverMessage]("match_over", MatchOverMessage{
+		Winners: winners,
+		Scores:  r.scoresUnsafe(),
+	})
+	if err != nil {
+		log.Printf("error marshalling match over message: %s", err)
+	} else {
+		r.broadcastUnsafe(nil, bs)
+	}
+	if len(winners) == 1 {
+		r.notifyAllUnsafe(fmt.Sprintf("Match over! Player #%d wins with %d points.", winners[0], top), false)
+	} else {
+		r.notifyAllUnsafe(fmt.Sprintf("Match over! It's a tie at %d points.", top), false)
+	}
+
+	// Start fresh for the next match
+	r.Scores = make(map[string]int)
+	r.Match.Reset()
+	r.publishScores()
+	r.publishMatch()
+}
+
+// publishOutcome reveals the prompt, the Poser, and the winner to all clients.
+// Not threadsafe.
+func (r *Room) publishOutcome() {
+	g := r.Game
+	m := OutcomeMessage{
+		Prompt:       g.Prompt,
+		Muse:         g.Muse + 1,
+		Poser:        g.Poser + 1,
+		Caught:       g.Outcome.Caught,
+		Guess:        g.Guess,
+		GuessCorrect: g.Outcome.GuessCorrect,
+		Winner:       Artist,
+	}
+	if g.Outcome.PoserWins() {
+		m.Winner = Poser
+	}
+	bs, err := MakeMessage[OutcomeMessage]("outcome", m)
+	if err != nil {
+		log.Printf("error marshalling outcome message: %s", err)
+	} else {
+		r.broadcastUnsafe(nil, bs)
+	}
+
+	var result string
+	switch {
+	case !m.Caught:
+		result = "The Poser escaped! The Poser and the Muse win."
+	case m.GuessCorrect:
+		result = "The Poser was caught, but guessed the prompt! The Poser and the Muse win."
+	default:
+		result = "The Poser was caught! The Artists win."
+	}
+	r.notifyAllUnsafe(fmt.Sprintf("The prompt was \"%s\" and Player #%d was the Poser. %s", m.Prompt, m.Poser, result), false)
+}
+
+// publishScores sends the scoreboard for all current players to all clients.
+// Not threadsafe.
+func (r *Room) publishScores() {
+	bs, err := MakeMessage[ScoresMessage]("scores", ScoresMessage{Scores: r.scoresUnsafe()})
+	if err != nil {
+		log.Printf("error marshalling scores message: %s", err)
+		return
+	}
+	r.broadcastUnsafe(nil, bs)
+}
+
+// connectionMessageUnsafe welcomes conn to the room with its ID, player number, and resume token.
+// Not threadsafe.
+func (r *Room) connectionMessageUnsafe(conn *Connection) ([]byte, error) {
+	m := &ConnectionMessage{
+		ID:           conn.ID,
+		PlayerNumber: conn.PlayerNumber,
+		Spectator:    conn.IsSpectator(),
+		Palette:      Palette,
+		Protocol:     ProtocolVersion,
+	}
+	// Spectators have no seat to resume
+	if r.Server != nil && !conn.IsSpectator() {
+		m.Token = r.Server.IssueToken(r.ID, conn.ID)
+	}
+	return MakeMessage("connection", m)
+}
+
+// slotIDUnsafe returns the ID of the player in slot i, including a disconnected player whose seat is held.
+// Returns "" for an empty slot.
+// Not threadsafe.
+func (r *Room) slotIDUnsafe(i int) string {
+	if conn := r.Slots[i]; conn != nil {
+		return conn.ID
+	}
+	if held, ok := r.Held[i]; ok {
+		return held.ID
+	}
+	return ""
+}
+
+// scoresUnsafe returns the scores of all current players, in player order.
+// Not threadsafe.
+func (r *Room) scoresUnsafe() []Score {
+	scores := make([]Score, 0, len(r.Conns))
+	for i, conn := range r.Slots {
+		if conn != nil {
+			scores = append(scores, Score{
+				ID:           conn.ID,
+				PlayerNumber: i + 1,
+				Score:        r.Scores[conn.ID],
+			})
+		}
+	}
+	return scores
+}
+
+// publishMatch sends the match configuration and progress to all clients.
+// Not threadsafe.
+func (r *Room) publishMatch() {
+	bs, err := r.matchMessageUnsafe()
+	if err != nil {
+		log.Printf("error marshalling match message: %s", err)
+		return
+	}
+	r.broadcastUnsafe(nil, bs)
+}
+
+// Not threadsafe.
+func (r *Room) matchMessageUnsafe() ([]byte, error) {
+	return MakeMessage[MatchMessage]("match", MatchMessage{
+		TargetScore: r.Match.TargetScore,
+		TargetGames: r.Match.TargetGames,
+		GamesPlayed: r.Match.GamesPlayed,
+	})
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Allowed values of string types used like enums in messages.
+var schemaEnums = map[reflect.Type][]any{
+	reflect.TypeOf(State("")):       {Waiting, GettingPrompt, Drawing, Voting, PoserGuessing, ValidatingGuess},
+	reflect.TypeOf(Role("")):        {Artist, Muse, Poser},
+	reflect.TypeOf(StrokePhase("")): {StrokeStart, StrokeSegment, StrokeEnd},
+	reflect.TypeOf(ErrorCode("")):   errorCodeValues(),
+}
+
+// All error codes, including CodeInternal for errors without a code of their own.
+func errorCodeValues() []any {
+	values := []any{CodeInternal, CodeUnknownType}
+	for _, e := range errorCodes {
+		values = append(values, e.code)
+	}
+	return values
+}
+
+// What kind of JSON value a protocolType is marshalled to
+type typeKind int
+
+const (
+	// Any JSON value, e.g. for interfaces
+	kindAny typeKind = iota
+	kindBoolean
+	kindInteger
+	kindNumber
+	kindString
+	// A time.Time, marshalled as an RFC 3339 string
+	kindTime
+	// A string type with a fixed set of values, listed in protocol.Enums
+	kindEnum
+	kindArray
+	// An object, since map keys are always strings in JSON, even for map[int]int
+	kindMap
+	// A struct, with its fields listed in protocol.Structs
+	kindStruct
+)
+
+// A protocolType describes how a Go type is marshalled to JSON.
+type protocolType struct {
+	Kind typeKind
+	// Type name of an enum or struct
+	Name string
+	// Element type of an array or map
+	Elem *protocolType
+}
+
+// A struct field as it appears in JSON
+type protocolField struct {
+	Name      string
+	OmitEmpty bool
+	Type      protocolType
+}
+
+// A protocolMessage is one type of message, with its payload type.
+type protocolMessage struct {
+	Type string
+	// Nil for messages without a payload
+	Payload *protocolType
+}
+
+// A protocol describes every message sent in each direction, and the types they use,
+// for the generators of the JSON Schema and TypeScript types to render.
+type protocol struct {
+	// Sorted by type, for stable output
+	Client, Server []protocolMessage
+	Structs        map[string][]protocolField
+	Enums          map[string][]any
+}
+
+// describeProtocol walks the payload types of every registered message.
+func describeProtocol() *protocol {
+	p := &protocol{Structs: map[string][]protocolField{}, Enums: map[string][]any{}}
+	clientTypes := map[string]reflect.Type{}
+	for messageType, handler := range messageHandlers {
+		clientTypes[messageType] = handler.Payload
+	}
+	p.Client = p.messages(clientTypes)
+	p.Server = p.messages(serverMessages)
+	return p
+}
+
+// messages describes a message of each of the given types.
+func (p *protocol) messages(payloads map[string]reflect.Type) []protocolMessage {
+	messages := make([]protocolMessage, 0, len(payloads))
+	for _, messageType := range sortedKeys(payloads) {
+		m := protocolMessage{Type: messageType}
+		if payload := payloads[messageType]; payload != reflect.TypeOf(NoPayload{}) {
+			t := p.describe(payload)
+			m.Payload = &t
+		}
+		messages = append(messages, m)
+	}
+	return messages
+}
+
+// describe describes how t is marshalled to JSON.
+//
+// Structs and enums are added to p under their type name, and referred to by that name.
+func (p *protocol) describe(t reflect.Type) protocolType {
+	if values, ok := schemaEnums[t]; ok {
+		p.Enums[t.Name()] = values
+		return protocolType{Kind: kindEnum, Name: t.Name()}
+	}
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return protocolType{Kind: kindTime}
+	case reflect.TypeOf(json.RawMessage{}):
+		return protocolType{Kind: kindAny}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return p.describe(t.Elem())
+	case reflect.Bool:
+		return protocolType{Kind: kindBoolean}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return protocolType{Kind: kindInteger}
+	case reflect.Float32, reflect.Float64:
+		return protocolType{Kind: kindNumber}
+	case reflect.String:
+		return protocolType{Kind: kindString}
+	case reflect.Slice, reflect.Array:
+		elem := p.describe(t.Elem())
+		return protocolType{Kind: kindArray, Elem: &elem}
+	case reflect.Map:
+		elem := p.describe(t.Elem())
+		return protocolType{Kind: kindMap, Elem: &elem}
+	case reflect.Struct:
+		if _, ok := p.Structs[t.Name()]; !ok {
+			// Claim the name first, in case the struct refers to itself
+			p.Structs[t.Name()] = nil
+			fields := []protocolField{}
+			for i := 0; i < t.NumField(); i++ {
+				if field, ok := p.field(t.Field(i)); ok {
+					fields = append(fields, field)
+				}
+			}
+			p.Structs[t.Name()] = fields
+		}
+		return protocolType{Kind: kindStruct, Name: t.Name()}
+	}
+	return protocolType{Kind: kindAny}
+}
+
+// field follows the json tag of field, returning false if it isn't marshalled at all.
+func (p *protocol) field(field reflect.StructField) (protocolField, bool) {
+	if !field.IsExported() {
+		return protocolField{}, false
+	}
+	name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return protocolField{}, false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return protocolField{
+		Name:      name,
+		OmitEmpty: strings.Contains(options, "omitempty"),
+		Type:      p.describe(field.Type),
+	}, true
+}
+
+// ProtocolSchema describes every message in the protocol as a JSON Schema, generated from the message structs.
+//
+// ClientMessage and ServerMessage in $defs match any valid message in each direction.
+func ProtocolSchema() map[string]any {
+	p := describeProtocol()
+	defs := map[string]any{}
+	for name, fields := range p.Structs {
+		defs[name] = p.structSchema(fields)
+	}
+	defs["ClientMessage"] = p.envelopeSchemas(p.Client)
+	defs["ServerMessage"] = p.envelopeSchemas(p.Server)
+	return map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     "/protocol.json",
+		"title":   "Poser websocket protocol",
+		"version": ProtocolVersion,
+		"$defs":   defs,
+		"anyOf": []any{
+			map[string]any{"$ref": "#/$defs/ClientMessage"},
+			map[string]any{"$ref": "#/$defs/ServerMessage"},
+		},
+	}
+}
+
+// envelopeSchemas matches whichever of messages applies.
+func (p *protocol) envelopeSchemas(messages []protocolMessage) map[string]any {
+	envelopes := make([]any, 0, len(messages))
+	for _, m := range messages {
+		// Messages without a payload can send anything, since it's ignored
+		data := map[string]any{}
+		if m.Payload != nil {
+			data = p.typeSchema(*m.Payload)
+		}
+		envelopes = append(envelopes, map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"type":      map[string]any{"const": m.Type},
+				"data":      data,
+				"seq":       map[string]any{"type": "integer"},
+				"timestamp": map[string]any{"type": "integer"},
+				"requestId": map[string]any{"type": "string"},
+			},
+			"required": []string{"type"},
+		})
+	}
+	return map[string]any{"oneOf": envelopes}
+}
+
+// typeSchema renders t as a JSON Schema, referring to structs in $defs.
+func (p *protocol) typeSchema(t protocolType) map[string]any {
+	switch t.Kind {
+	case kindBoolean:
+		return map[string]any{"type": "boolean"}
+	case kindInteger:
+		return map[string]any{"type": "integer"}
+	case kindNumber:
+		return map[string]any{"type": "number"}
+	case kindString:
+		return map[string]any{"type": "string"}
+	case kindTime:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case kindEnum:
+		return map[string]any{"type": "string", "enum": p.Enums[t.Name]}
+	case kindArray:
+		return map[string]any{"type": "array", "items": p.typeSchema(*t.Elem)}
+	case kindMap:
+		return map[string]any{"type": "object", "additionalProperties": p.typeSchema(*t.Elem)}
+	case kindStruct:
+		return map[string]any{"$ref": "#/$defs/" + t.Name}
+	}
+	return map[string]any{}
+}
+
+// structSchema renders the fields of a struct as a JSON Schema object.
+func (p *protocol) structSchema(fields []protocolField) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for _, field := range fields {
+		properties[field.Name] = p.typeSchema(field.Type)
+		if !field.OmitEmpty {
+			required = append(required, field.Name)
+		}
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// ProtocolHandler serves the protocol's JSON Schema; see ProtocolSchema.
+func ProtocolHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	if err := json.NewEncoder(w).Encode(ProtocolSchema()); err != nil {
+		log.Printf("failed to write protocol schema: %s", err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+)
+
+// Resume tokens let a client reclaim its seat after its connection drops.
+//
+// A token is the connection ID plus an HMAC of the room and connection IDs,
+// so it can't be forged, and can't be used to take a seat in another room.
+// Tokens are signed with a per-process secret, so they don't survive a server restart,
+// but neither do rooms.
+
+// IssueToken creates a resume token for connection connId in room roomId.
+func (s *Server) IssueToken(roomId, connId string) string {
+	id := base64.RawURLEncoding.EncodeToString([]byte(connId))
+	sig := base64.RawURLEncoding.EncodeToString(s.sign(roomId, connId))
+	return id + "." + sig
+}
+
+// VerifyToken checks a resume token for room roomId, returning the connection ID it was issued to.
+func (s *Server) VerifyToken(roomId, token string) (connId string, ok bool) {
+	encodedId, encodedSig, found := strings.Cut(token, ".")
+	if !found {
+		return "", false
+	}
+	id, err := base64.RawURLEncoding.DecodeString(encodedId)
+	if err != nil {
+		return "", false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return "", false
+	}
+	if !hmac.Equal(sig, s.sign(roomId, string(id))) {
+		return "", false
+	}
+	return string(id), true
+}
+
+func (s *Server) sign(roomId, connId string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	// Room IDs come straight from the URL, so they may contain anything.
+	// Prefix each field with its length, so no two pairs of IDs sign the same bytes.
+	for _, field := range []string{roomId, connId} {
+		binary.Write(mac, binary.BigEndian, uint64(len(field)))
+		mac.Write([]byte(field))
+	}
+	return mac.Sum(nil)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestVerifyToken(t *testing.T) {
+	s := NewServer()
+	token := s.IssueToken("room-a", "user-1")
+	_, sig, _ := strings.Cut(token, ".")
+	forgedId := base64.RawURLEncoding.EncodeToString([]byte("user-2"))
+	// Change the first character of the signature
+	tampered := "A"
+	if sig[0] == 'A' {
+		tampered = "B"
+	}
+	tampered = strings.Replace(token, "."+sig[:1], "."+tampered, 1)
+	// Room IDs can contain anything, so shift the boundary between the room and connection IDs
+	shifted := s.IssueToken("room-a\nuser", "1")
+	_, shiftedSig, _ := strings.Cut(shifted, ".")
+	shiftedId := base64.RawURLEncoding.EncodeToString([]byte("user\n1"))
+
+	tests := []struct {
+		name   string
+		server *Server
+		room   string
+		token  string
+		wantId string
+		wantOk bool
+	}{
+		{"valid", s, "room-a", token, "user-1", true},
+		{"wrong room", s, "room-b", token, "", false},
+		{"other connection's ID", s, "room-a", forgedId + "." + sig, "", false},
+		{"shifted boundary", s, "room-a", shiftedId + "." + shiftedSig, "", false},
+		{"tampered signature", s, "room-a", tampered, "", false},
+		{"no signature", s, "room-a", strings.Split(token, ".")[0], "", false},
+		{"bad encoding", s, "room-a", "!!!." + sig, "", false},
+		{"empty", s, "room-a", "", "", false},
+		// Secrets are per process, so tokens don't carry over to another server
+		{"other server", NewServer(), "room-a", token, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, ok := tt.server.VerifyToken(tt.room, tt.token)
+			if id != tt.wantId || ok != tt.wantOk {
+				t.Errorf("VerifyToken() = %q, %t, want %q, %t", id, ok, tt.wantId, tt.wantOk)
+			}
+		})
+	}
+}
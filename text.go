@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// cleanText tidies up text typed by a player before anyone else sees it.
+//
+// Text is NFC-normalized, invisible and control characters are dropped,
+// and runs of whitespace are collapsed into a single space.
+func cleanText(text string) string {
+	text = norm.NFC.String(text)
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+		if !unicode.IsPrint(r) {
+			return -1
+		}
+		return r
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// containsPhrase checks if text mentions phrase, ignoring case, spacing, punctuation, and simple plurals.
+//
+// Words are matched whole, so "cat" doesn't match "concatenate",
+// but any run of words can spell out the phrase, so "hot dog" matches "hotdogs" and "h o t d o g".
+func containsPhrase(text string, phrase string) bool {
+	target := strings.Join(words(phrase), "")
+	if target == "" {
+		return false
+	}
+	tokens := words(text)
+	for i := range tokens {
+		run := ""
+		for _, token := range tokens[i:] {
+			run += token
+			if run == target || isPluralOf(run, target) || isPluralOf(target, run) {
+				return true
+			}
+			// Allow for a plural ending, but no more
+			if len(run) > len(target)+3 {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// words splits text into lowercase words of letters and digits.
+//
+// Text is NFKC-normalized first, so look-alikes such as full-width letters count as the letters they look like.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(norm.NFKC.String(cleanText(text))), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// isPluralOf checks if plural is a regular English plural of singular,
+// e.g. "horses" of "horse", "boxes" of "box", or "puppies" of "puppy".
+//
+// Only whole endings are added, so "not" isn't mistaken for a form of "note", nor "notes" for a form of "not".
+func isPluralOf(plural string, singular string) bool {
+	if singular == "" {
+		return false
+	}
+	if plural == singular+"s" && !strings.HasSuffix(singular, "s") {
+		return true
+	}
+	if plural == singular+"es" {
+		for _, ending := range []string{"s", "x", "z", "ch", "sh", "o"} {
+			if strings.HasSuffix(singular, ending) {
+				return true
+			}
+		}
+		return false
+	}
+	base, ok := strings.CutSuffix(singular, "y")
+	return ok && len(base) > 1 && plural == base+"ies"
+}
@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestContainsPhrase(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		phrase string
+		want   bool
+	}{
+		{"word", "it's a cat", "cat", true},
+		{"case and punctuation", "CAT!!!", "cat", true},
+		{"plural", "lots of cats", "cat", true},
+		{"plural with es", "three boxes", "box", true},
+		{"plural with ies", "two puppies", "puppy", true},
+		{"singular of a plural prompt", "one horse", "horses", true},
+		{"phrase", "a hot dog stand", "hot dog", true},
+		{"phrase run together", "hotdogs", "hot dog", true},
+		{"phrase spelled out", "h o t d o g", "hot dog", true},
+		{"full-width letters", "ｃａｔ", "cat", true},
+		{"inside another word", "concatenate", "cat", false},
+		{"different word", "it's a dog", "cat", false},
+		{"blank prompt", "anything", "  ", false},
+		// Words that differ from the prompt by a trailing e are different words
+		{"not for note", "I'm not sure", "note", false},
+		{"can for cane", "can you see it", "cane", false},
+		{"on for one", "turn it on", "one", false},
+		{"plan for plane", "what's the plan", "plane", false},
+		{"not for notes", "I'm not sure", "notes", false},
+		{"note for not", "take a note", "not", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := containsPhrase(tt.text, tt.phrase); got != tt.want {
+				t.Errorf("containsPhrase(%q, %q) = %t, want %t", tt.text, tt.phrase, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPluralOf(t *testing.T) {
+	tests := []struct {
+		plural, singular string
+		want             bool
+	}{
+		{"cats", "cat", true},
+		{"horses", "horse", true},
+		{"boxes", "box", true},
+		{"dishes", "dish", true},
+		{"potatoes", "potato", true},
+		{"puppies", "puppy", true},
+		{"days", "day", true},
+		{"cat", "cat", false},
+		{"cat", "cats", false},
+		{"note", "not", false},
+		{"notes", "not", false},
+		{"glasss", "glass", false},
+		{"s", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.plural+"/"+tt.singular, func(t *testing.T) {
+			if got := isPluralOf(tt.plural, tt.singular); got != tt.want {
+				t.Errorf("isPluralOf(%q, %q) = %t, want %t", tt.plural, tt.singular, got, tt.want)
+			}
+		})
+	}
+}
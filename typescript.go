@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProtocolTypeScript declares every message in the protocol as TypeScript types,
+// generated from the message structs just like ProtocolSchema.
+//
+// The output is committed as frontend/src/app/protocol.ts (see `make types`),
+// and TestProtocolTypeScript fails if that file falls behind the structs.
+func ProtocolTypeScript() string {
+	p := describeProtocol()
+
+	var b strings.Builder
+	b.WriteString("// Code generated by `go run . -types`; DO NOT EDIT.\n")
+	b.WriteString("// Types of the websocket protocol's messages, generated from the Go message structs.\n\n")
+	fmt.Fprintf(&b, "export const PROTOCOL_VERSION = %d;\n", ProtocolVersion)
+	fmt.Fprintf(&b, "export const MIN_PROTOCOL_VERSION = %d;\n", MinProtocolVersion)
+
+	for _, name := range sortedKeys(p.Enums) {
+		values := make([]string, len(p.Enums[name]))
+		for i, value := range p.Enums[name] {
+			values[i] = fmt.Sprintf("'%s'", value)
+		}
+		fmt.Fprintf(&b, "\nexport type %s =\n  | %s;\n", name, strings.Join(values, "\n  | "))
+	}
+	for _, name := range sortedKeys(p.Structs) {
+		fmt.Fprintf(&b, "\nexport interface %s {\n", name)
+		for _, field := range p.Structs[name] {
+			optional := ""
+			if field.OmitEmpty {
+				optional = "?"
+			}
+			fmt.Fprintf(&b, "  %s%s: %s;\n", field.Name, optional, tsType(field.Type))
+		}
+		b.WriteString("}\n")
+	}
+
+	b.WriteString("\n// Fields common to every message\n")
+	b.WriteString("interface Envelope {\n  seq?: number;\n  timestamp?: number;\n  requestId?: string;\n}\n")
+	fmt.Fprintf(&b, "\n// A message sent by the client to the server\nexport type ClientMessage =\n%s;\n", envelopeTypeScript(p.Client))
+	fmt.Fprintf(&b, "\n// A message sent by the server to the client\nexport type ServerMessage =\n%s;\n", envelopeTypeScript(p.Server))
+	return b.String()
+}
+
+// envelopeTypeScript declares a union of messages.
+func envelopeTypeScript(messages []protocolMessage) string {
+	lines := make([]string, 0, len(messages))
+	for _, m := range messages {
+		// Messages without a payload can send anything, since it's ignored
+		data := "data?: unknown"
+		if m.Payload != nil {
+			data = "data: " + tsType(*m.Payload)
+		}
+		lines = append(lines, fmt.Sprintf("  | Envelope & { type: '%s'; %s }", m.Type, data))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tsType names the TypeScript type of t, referring to structs and enums by name.
+func tsType(t protocolType) string {
+	switch t.Kind {
+	case kindBoolean:
+		return "boolean"
+	case kindInteger, kindNumber:
+		return "number"
+	case kindString, kindTime:
+		return "string"
+	case kindEnum, kindStruct:
+		return t.Name
+	case kindArray:
+		return tsType(*t.Elem) + "[]"
+	case kindMap:
+		return fmt.Sprintf("Record<string, %s>", tsType(*t.Elem))
+	}
+	return "unknown"
+}
+
+// sortedKeys returns the keys of m in order, for stable output.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// Where `make types` writes ProtocolTypeScript for the frontend
+const protocolTypesPath = "frontend/src/app/protocol.ts"
+
+func TestProtocolTypeScript(t *testing.T) {
+	committed, err := os.ReadFile(protocolTypesPath)
+	if err != nil {
+		t.Fatalf("reading %s: %s", protocolTypesPath, err)
+	}
+	if string(committed) != ProtocolTypeScript() {
+		t.Errorf("%s is out of date with the message structs; run `make types`", protocolTypesPath)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -17,27 +18,55 @@ func MustRead(file string) string {
 	return string(b)
 }
 
-var indexHTML = MustRead("./frontend/dist/home.html")
-var roomHTML = MustRead("./frontend/dist/app.html")
+// Built frontend pages, read by LoadPages when the server starts.
+//
+// These aren't read at init, so the package works without a built frontend, e.g. for tests or -schema.
+var indexHTML string
+var roomHTML string
+
+// LoadPages reads the built frontend pages, exiting if they're missing.
+func LoadPages() {
+	indexHTML = MustRead("./frontend/dist/home.html")
+	roomHTML = MustRead("./frontend/dist/app.html")
+}
 
 // The home page serves a page with a button to create a new room
 func HomeHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(indexHTML))
 }
 
-// NewRoomHandler just creates a UUID for a new room, then redirects the user.
+// NewRoomHandler creates a room with a new UUID and the requested config, then redirects the user.
+//
+// The config can be sent as either form values or JSON; see ParseRoomConfig.
 //
 // It would be cool to store all recent room IDs in a cookie,
 // then render the list on the homepage for a user to return to.
-// But for now we just forward them to a room URL, which will autogenerate the room.
-func NewRoomHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) NewRoomHandler(w http.ResponseWriter, r *http.Request) {
+	config, err := ParseRoomConfig(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	// Homepage requested a new room
 	//TODO could instead do some sort of TinyURL style Base58(SHA256(url, username))
-	id := uuid.New()
+	id := uuid.New().String()
+	if _, err := s.CreateRoom(id, config); err != nil {
+		log.Printf("failed to create room: %s", err)
+		http.Error(w, "Couldn't create room", http.StatusInternalServerError)
+		return
+	}
 	roomPath := fmt.Sprintf("/room/%s", id)
 	http.Redirect(w, r, roomPath, http.StatusFound)
 }
 
+// RoomListHandler serves a JSON list of public rooms.
+func (s *Server) RoomListHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.PublicRooms()); err != nil {
+		log.Printf("failed to write room list: %s", err)
+	}
+}
+
 // RoomHandler serves the room assets
 func RoomHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(roomHTML))
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -15,14 +19,163 @@ import (
 
 // Taken with much inspiration from https://dev.to/nyxtom/realtime-collaborative-drawing-with-canvas-and-webrtc-2d01
 
-// TODO make this configurable on room creation
-const roomSize = 8
+// Rooms created from the home page but never joined are deleted after this long.
+const abandonedRoomTimeout = 10 * time.Minute
+
+const (
+	// Outbound messages queued per connection before it's considered too slow to keep up
+	sendBufferSize = 256
+	// How long a single write can take before the connection is considered dead
+	writeWait = 10 * time.Second
+	// How long to wait for a pong before the connection is considered dead.
+	// This catches half-open connections, e.g. from a laptop that went to sleep.
+	pongWait = 60 * time.Second
+	// How often to ping the client; must be less than pongWait
+	pingPeriod = pongWait * 9 / 10
+)
+
+var ErrSlowConsumer = errors.New("connection can't keep up with messages")
+var ErrConnectionClosed = errors.New("connection is closed")
 
 // Connection is a wrapper around websocket.Conn that also stores a local ID
+//
+// websocket.Conn doesn't support concurrent writers, so all writes go through Send,
+// which queues messages for a single goroutine to write; see writePump.
 type Connection struct {
 	*websocket.Conn
 	ID           string
 	PlayerNumber int
+	// When the player first joined the room; used to pick a new owner
+	Joined time.Time
+	// Remote IP address, for bans
+	IP string
+	// Set when the host kicks this connection, so its seat isn't held for it
+	kicked bool
+	// Rate limit for chat messages
+	chatLimiter *TokenBucket
+
+	// Outbound messages waiting on writePump
+	send chan outbound
+	// Closed when the connection is closing, so writePump can stop
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// An outbound websocket message, queued for writePump.
+type outbound struct {
+	messageType int
+	data        []byte
+}
+
+// NewConnection wraps wsConn, and starts writing messages sent to it.
+func NewConnection(wsConn *websocket.Conn, ip string) *Connection {
+	c := newConnection(wsConn, ip)
+	// Any read after a missed heartbeat fails, which ends the read loop and removes the connection
+	c.SetReadDeadline(time.Now().Add(pongWait))
+	c.SetPongHandler(func(string) error {
+		return c.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	go c.writePump()
+	return c
+}
+
+// newConnection sets up a connection without touching wsConn or starting writePump.
+//
+// Messages sent to it just queue up in c.send, so tests can pass a nil wsConn and read them from there.
+func newConnection(wsConn *websocket.Conn, ip string) *Connection {
+	return &Connection{
+		Conn: wsConn,
+		ID:   fmt.Sprintf("user-%s", uuid.New().String()),
+		// PlayerNumber is for distinguishing players' seats. Assigned later.
+		PlayerNumber: 0,
+		Joined:       time.Now(),
+		IP:           ip,
+		chatLimiter:  NewTokenBucket(chatBurst, chatRefill),
+		send:         make(chan outbound, sendBufferSize),
+		done:         make(chan struct{}),
+	}
+}
+
+// Send queues a text message to be written to the client.
+//
+// This never blocks: if the client isn't keeping up, it's disconnected instead,
+// which frees up its seat through the usual path once the read loop notices.
+func (c *Connection) Send(message []byte) error {
+	return c.enqueue(outbound{websocket.TextMessage, message})
+}
+
+func (c *Connection) enqueue(m outbound) error {
+	select {
+	case <-c.done:
+		return ErrConnectionClosed
+	default:
+	}
+	select {
+	case c.send <- m:
+		return nil
+	default:
+		log.Printf("dropping slow connection %s", c.ID)
+		c.closeOnce.Do(func() { close(c.done) })
+		if c.Conn != nil {
+			c.Conn.Close()
+		}
+		return ErrSlowConsumer
+	}
+}
+
+// Close stops accepting new messages, and closes the connection once queued messages are written.
+//
+// This shadows websocket.Conn.Close, so nothing closes the connection out from under writePump.
+func (c *Connection) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return nil
+}
+
+// writePump writes queued messages to the client, one at a time, and pings it every pingPeriod.
+//
+// This is the only goroutine that writes to the websocket connection.
+func (c *Connection) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	defer c.Conn.Close()
+	for {
+		select {
+		case m := <-c.send:
+			if err := c.write(m); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := c.write(outbound{websocket.PingMessage, nil}); err != nil {
+				return
+			}
+		case <-c.done:
+			// Flush anything queued before closing, e.g. a close message
+			for {
+				select {
+				case m := <-c.send:
+					if err := c.write(m); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *Connection) write(m outbound) error {
+	c.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := c.WriteMessage(m.messageType, m.data); err != nil {
+		log.Printf("error writing to %s: %s", c.ID, err)
+		return err
+	}
+	return nil
+}
+
+// IsSpectator checks if the connection is watching the room without a seat.
+func (c *Connection) IsSpectator() bool {
+	return c.PlayerNumber == 0
 }
 
 func (c *Connection) Notify(message string, isErr bool) {
@@ -35,16 +188,42 @@ func (c *Connection) Notify(message string, isErr bool) {
 		log.Printf("failed to notify client: %s", err)
 		return
 	}
-	c.WriteMessage(websocket.TextMessage, bs)
+	c.Send(bs)
+}
+
+// CloseWithReason sends a close frame explaining why the server is closing the connection, then closes it.
+func (c *Connection) CloseWithReason(code int, reason string) {
+	err := c.enqueue(outbound{websocket.CloseMessage, websocket.FormatCloseMessage(code, reason)})
+	if err != nil {
+		log.Printf("failed to send close message: %s", err)
+	}
+	c.Close()
+}
+
+// SendError tells the client that the server couldn't handle a message it sent.
+//
+// requestID is the ID the client gave that message, if any.
+func (c *Connection) SendError(requestID string, code ErrorCode, message string) {
+	rawJson, err := json.Marshal(&ErrorMessage{Code: code, Message: message})
+	if err != nil {
+		log.Printf("failed to send error to client: %s", err)
+		return
+	}
+	bs, err := json.Marshal(&Message{Type: "error", Data: rawJson, RequestID: requestID})
+	if err != nil {
+		log.Printf("failed to send error to client: %s", err)
+		return
+	}
+	c.Send(bs)
 }
 
-func (c *Connection) SendState(state State) {
-	bs, err := MakeMessage("state", &StateMessage{State: state})
+func (c *Connection) SendRole(role Role) {
+	bs, err := MakeMessage("role", &RoleMessage{Role: role})
 	if err != nil {
-		log.Printf("failed to send state to client client: %s", err)
+		log.Printf("failed to send role to client: %s", err)
 		return
 	}
-	c.WriteMessage(websocket.TextMessage, bs)
+	c.Send(bs)
 }
 
 var upgrader = websocket.Upgrader{
@@ -54,21 +233,79 @@ var upgrader = websocket.Upgrader{
 
 type Server struct {
 	RoomCache sync.Map
+	// Key for signing resume tokens
+	secret []byte
 }
 
 func NewServer() *Server {
-	return &Server{RoomCache: sync.Map{}}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("failed to generate server secret: %s", err)
+	}
+	return &Server{RoomCache: sync.Map{}, secret: secret}
 }
 
+// GetOrCreateRoom finds a room by ID, creating it with the default config if it doesn't exist.
 func (s *Server) GetOrCreateRoom(roomId string) *Room {
-	//TODO get roomSize from / form
-	room, loaded := s.RoomCache.LoadOrStore(roomId, NewRoom(roomId, roomSize))
+	if room, ok := s.RoomCache.Load(roomId); ok {
+		return room.(*Room)
+	}
+	newRoom, err := NewRoom(roomId, DefaultRoomConfig())
+	if err != nil {
+		// Default config is always valid
+		log.Fatalf("invalid default room config: %s", err)
+	}
+	newRoom.Server = s
+	room, loaded := s.RoomCache.LoadOrStore(roomId, newRoom)
 	if !loaded {
 		log.Printf("Created new room %s", roomId)
 	}
 	return room.(*Room)
 }
 
+// CreateRoom stores a new room with the given config.
+//
+// Since the room exists before anyone joins it, it's deleted if nobody shows up.
+func (s *Server) CreateRoom(roomId string, config RoomConfig) (*Room, error) {
+	room, err := NewRoom(roomId, config)
+	if err != nil {
+		return nil, err
+	}
+	room.Server = s
+	if _, loaded := s.RoomCache.LoadOrStore(roomId, room); loaded {
+		return nil, fmt.Errorf("room %s already exists", roomId)
+	}
+	log.Printf("Created new room %s with config %+v", roomId, config)
+	time.AfterFunc(abandonedRoomTimeout, func() {
+		if room.IsEmpty() {
+			log.Printf("Abandoned room %s", room.ID)
+			s.DeleteRoom(room)
+		}
+	})
+	return room, nil
+}
+
+// DeleteRoom removes room from the cache.
+//
+// This only deletes that exact room, in case a new room with the same ID has already replaced it.
+func (s *Server) DeleteRoom(room *Room) {
+	log.Printf("Deleting room %s", room.ID)
+	s.RoomCache.CompareAndDelete(room.ID, room)
+}
+
+// PublicRooms lists summaries of all public rooms.
+func (s *Server) PublicRooms() []RoomSummary {
+	rooms := make([]RoomSummary, 0)
+	s.RoomCache.Range(func(_, value any) bool {
+		room := value.(*Room)
+		if room.Config.Public {
+			rooms = append(rooms, room.Summary())
+		}
+		return true
+	})
+	return rooms
+}
+
 func (s *Server) HandleWebsocket(w http.ResponseWriter, r *http.Request) {
 	roomId, ok := mux.Vars(r)["room"]
 	if !ok {
@@ -76,71 +313,89 @@ func (s *Server) HandleWebsocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	room := s.GetOrCreateRoom(roomId)
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
 
 	wsConn, err := upgrader.Upgrade(w, r, nil)
-	conn := &Connection{
-		Conn: wsConn,
-		ID:   fmt.Sprintf("user-%s", uuid.New().String()),
-		// PlayerNumber is for distinguishing things like user's color. Assigned later.
-		PlayerNumber: 0,
-	}
-	defer conn.Close()
 	if err != nil {
 		// Upgrade() already wrote an error message, so just log error and return.
 		log.Printf("failed to upgrade connection: %s", err)
 		return
 	}
+	conn := NewConnection(wsConn, ip)
+	defer conn.Close()
 
-	//TODO handle case where user ID already exists
-	if err = room.Add(conn); err != nil {
-		if err == ErrRoomFull {
-			conn.Notify("Room is currently full.", true)
-		} else {
-			log.Printf("Error adding user to room: %s", err)
-			conn.Notify("Couldn't add user to room.", true)
-		}
-		// For now, let's just close the websocket. Later, we could implement spectating.
+	// Turn away clients that won't understand us before they take a seat
+	version, err := strconv.Atoi(r.URL.Query().Get("protocol"))
+	if err != nil || version < MinProtocolVersion || version > ProtocolVersion {
+		log.Printf("rejecting client with protocol version %q", r.URL.Query().Get("protocol"))
+		conn.CloseWithReason(websocket.CloseProtocolError, "Unsupported protocol version. Please reload the page.")
 		return
 	}
-	log.Printf("New connection from %s", conn.RemoteAddr())
+	// Only now is the client worth a room, so rejected clients don't leave empty ones behind
+	room := s.GetOrCreateRoom(roomId)
+
+	// Try to reclaim a held seat first, falling back to joining as a new player.
+	resumed := false
+	if token := r.URL.Query().Get("token"); token != "" {
+		if id, ok := s.VerifyToken(room.ID, token); ok {
+			resumed = room.Resume(conn, id) == nil
+		}
+	}
+	if !resumed {
+		if err = room.Add(conn); err != nil {
+			if err == ErrRoomFull {
+				conn.SendError("", CodeRoomFull, "Room is currently full.")
+			} else if err == ErrBanned {
+				conn.CloseWithReason(websocket.ClosePolicyViolation, "You are banned from this room.")
+			} else {
+				log.Printf("Error adding user to room: %s", err)
+				conn.SendError("", CodeInternal, "Couldn't add user to room.")
+			}
+			if room.IsEmpty() {
+				s.DeleteRoom(room)
+			}
+			return
+		}
+	}
+	log.Printf("New connection from %s (resumed: %t)", conn.RemoteAddr(), resumed)
+	if name := r.URL.Query().Get("name"); name != "" && room.Name(conn) == "" {
+		if err := room.SetName(conn, name); err != nil {
+			conn.Notify(fmt.Sprintf("Couldn't set your name: %s", err), true)
+		}
+	}
 	defer func() {
-		//TODO handle room owner leaving (assign new?)
-		//TODO handle prompt writer leaving (assign new prompt writer)
-		//TODO handle final voter leaving (register voting as complete)
-		//TODO handle fake artist leaving during game (make a note? or just end game?)
-		//     Could just check for this after voting. (State PoserGuessing
-		//     "You voted for X. Fake artist was Y, who left."
-		//TODO handle fake artist leaving during guess (end game)
+		// Departures from a game in progress are handled once the player's held seat is released.
 		log.Printf("Closing connection to %s", conn.RemoteAddr())
 		if room.Remove(conn) == 0 { // If everyone has now left, delete the room
-			log.Printf("Deleting room %s", room.ID)
-			s.RoomCache.Delete(room.ID)
+			s.DeleteRoom(room)
 		} else { // Otherwise, let remaining users know this user left
 			room.BroadcastConnections()
 		}
 	}()
 
 	// Send user their ID
-	bs, err := MakeMessage("connection", &ConnectionMessage{
-		ID:           conn.ID,
-		PlayerNumber: conn.PlayerNumber,
-	})
-	if err != nil {
-		log.Printf("Error marshalling connection: %s", err)
-		conn.WriteControl(websocket.CloseMessage,
-			// Don't share actual error to avoid violating same-origin policy
-			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "Internal error"),
-			time.Now().Add(500*time.Millisecond))
-		return
-	}
-	err = conn.WriteMessage(websocket.TextMessage, bs)
-	if err != nil {
+	if err := room.SendConnection(conn); err != nil {
 		log.Printf("Failed to send user ID: %s", err)
+		// Don't share actual error to avoid violating same-origin policy
+		conn.CloseWithReason(websocket.CloseInternalServerErr, "Internal error")
 		return
 	}
+	// Catch user up on the canvas
+	room.SendStrokes(conn)
+	if resumed || conn.IsSpectator() {
+		room.SendGameState(conn)
+	}
+	if conn.IsSpectator() {
+		conn.Notify("You're spectating. You'll get a seat when one opens up between games.", false)
+		room.SendQueuePosition(conn)
+	}
 	// Send all IDs
 	room.BroadcastConnections()
+	room.BroadcastScores()
+	room.SendMatch(conn)
 
 LOOP:
 	for {
@@ -152,79 +407,12 @@ LOOP:
 		}
 
 		// basically the same processing for the parsed message as for the websocket message
-		messageType, data, err := ParseMessage(message)
+		m, err := ParseMessage(message)
 		if err != nil {
 			log.Printf("Error parsing message: %s", err)
+			conn.SendError("", CodeInvalidPayload, "Couldn't understand that message.")
 			continue LOOP
 		}
-		switch messageType {
-		case "chat":
-			m := &ChatMessage{}
-			err := json.Unmarshal(data, m)
-			if err != nil {
-				log.Printf("Error unmarshalling chat message: %s", err)
-				continue LOOP
-			}
-			//TODO check timestamp?
-			//TODO sanitize text
-			// Set user ID, ignore anything client may have set.
-			m.User = conn.ID
-			m.PlayerNumber = conn.PlayerNumber
-			// Set message ID - these have to be distinct on the client side.
-			m.ID = fmt.Sprintf("msg-%s", uuid.New().String())
-			log.Printf("%s:%s: %s", room.ID, conn.RemoteAddr(), message)
-			err = BroadcastType[*ChatMessage](room, nil, "chat", m)
-			if err != nil {
-				log.Println(err)
-			}
-			continue LOOP
-		case "done": // User finished their turn
-			if conn.PlayerNumber-1 != room.Game.Drawing {
-				conn.Notify("Server received done from your client, but it is not your turn.", true)
-				continue LOOP
-			}
-			go room.EndTurn(conn.PlayerNumber - 1)
-		case "draw":
-			m := &DrawMessage{}
-			err := json.Unmarshal(data, m)
-			if err != nil {
-				log.Printf("Error unmarshalling draw message: %s", err)
-				continue LOOP
-			}
-			//TODO set source user ID
-			err = BroadcastType[*DrawMessage](room, conn, "draw", m)
-			if err != nil {
-				log.Println(err)
-			}
-			continue LOOP
-		case "prompt":
-			if conn.PlayerNumber-1 != room.Game.Muse {
-				conn.Notify("Server received prompt from your client, but you are not the Muse.", true)
-				continue LOOP
-			}
-			m := &PromptMessage{}
-			if err := json.Unmarshal(data, m); err != nil {
-				log.Printf("Error unmarshalling prompt message: %s", err)
-				continue LOOP
-			}
-			go room.SetPrompt(m.Prompt)
-		case "start":
-			// Nothing to parse from data
-			if conn.PlayerNumber != 1 {
-				conn.Notify(fmt.Sprintf("You cannot start the game as player #%d.", conn.PlayerNumber), true)
-				continue LOOP
-			}
-			if room.Game.State != Waiting {
-				// Just ignore, player may have accidentally sent this
-				continue LOOP
-			}
-			//TODO maybe just return an error here?
-			go room.Start()
-		default:
-			//DEBUG Just broadcast messages to all other room members for now
-			log.Printf("%s:%s: unexpected message: %s", room.ID, conn.RemoteAddr(), message)
-			go room.Broadcast(conn, message)
-		}
-
+		HandleMessage(room, conn, m)
 	}
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// newTestServer serves s's websocket endpoint, returning the URL to dial for room.
+func newTestServer(t *testing.T, s *Server, room string) string {
+	t.Helper()
+	r := mux.NewRouter()
+	r.HandleFunc("/ws/{room}", s.HandleWebsocket)
+	ts := httptest.NewServer(r)
+	t.Cleanup(ts.Close)
+	return "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/" + room
+}
+
+func TestHandleWebsocketRejectsProtocol(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"missing", ""},
+		{"not a number", "?protocol=latest"},
+		{"too old", "?protocol=0"},
+		{"too new", "?protocol=999"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer()
+			url := newTestServer(t, s, "made-up-room")
+			ws, _, err := websocket.DefaultDialer.Dial(url+tt.query, nil)
+			if err != nil {
+				t.Fatalf("Dial: %s", err)
+			}
+			defer ws.Close()
+
+			_, _, err = ws.ReadMessage()
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseProtocolError {
+				t.Fatalf("ReadMessage() = %v, want close with code %d", err, websocket.CloseProtocolError)
+			}
+			// The rejected client shouldn't leave an empty room behind
+			if _, ok := s.RoomCache.Load("made-up-room"); ok {
+				t.Errorf("room was created for a client with an unsupported protocol")
+			}
+		})
+	}
+}